@@ -41,6 +41,20 @@ func CallbackHandler(config *oauth2.Config, success, failure http.Handler) http.
 	return oauth2Login.CallbackHandler(config, success, failure)
 }
 
+// NewProvider returns a gologin.Provider for mounting the Bitbucket StateHandler,
+// LoginHandler, and CallbackHandler on a gologin.Registry. The options may
+// enable PKCE (nil for defaults).
+func NewProvider(config *oauth2.Config, stateConfig gologin.CookieConfig, options *oauth2Login.ProviderOptions) gologin.Provider {
+	return gologin.ProviderFuncs{
+		Login: func(failure http.Handler) http.Handler {
+			return oauth2Login.ProviderHandler(stateConfig, options, LoginHandler(config, failure))
+		},
+		Callback: func(success, failure http.Handler) http.Handler {
+			return oauth2Login.ProviderHandler(stateConfig, options, CallbackHandler(config, success, failure))
+		},
+	}
+}
+
 // bitbucketHandler is a http.Handler that gets the OAuth2 Token from the ctx
 // to get the corresponding Bitbucket User. If successful, the User is added to
 // the ctx and the success handler is called. Otherwise, the failure handler is
@@ -67,6 +81,7 @@ func bitbucketHandler(config *oauth2.Config, success, failure http.Handler) http
 			return
 		}
 		ctx = WithUser(ctx, user)
+		ctx = gologin.WithIdentity(ctx, newIdentity(user))
 		success.ServeHTTP(w, req.WithContext(ctx))
 	}
 	return http.HandlerFunc(fn)
@@ -78,8 +93,19 @@ func validateResponse(user *User, resp *http.Response, err error) error {
 	if err != nil || resp.StatusCode != http.StatusOK {
 		return ErrUnableToGetBitbucketUser
 	}
-	if user == nil || user.Username == "" {
+	if user == nil || user.UUID == "" {
 		return ErrUnableToGetBitbucketUser
 	}
 	return nil
 }
+
+// newIdentity returns the gologin Identity of the Bitbucket User.
+func newIdentity(user *User) *gologin.Identity {
+	return &gologin.Identity{
+		Provider: "bitbucket",
+		Subject:  user.UUID,
+		Name:     user.DisplayName,
+		Username: user.Username,
+		Raw:      user,
+	}
+}
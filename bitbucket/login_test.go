@@ -15,8 +15,8 @@ import (
 )
 
 func TestBitbucketHandler(t *testing.T) {
-	jsonData := `{"username": "bitster", "display_name": "Atlas Ian"}`
-	expectedUser := &User{Username: "bitster", DisplayName: "Atlas Ian"}
+	jsonData := `{"uuid": "{4c0a5e86-9d0b-4ad5-8e1f-2c3b7a0f1d2e}", "username": "bitster", "display_name": "Atlas Ian"}`
+	expectedUser := &User{UUID: "{4c0a5e86-9d0b-4ad5-8e1f-2c3b7a0f1d2e}", Username: "bitster", DisplayName: "Atlas Ian"}
 	proxyClient, server := newBitbucketTestServer(jsonData)
 	defer server.Close()
 	// oauth2 Client will use the proxy client's base Transport
@@ -30,6 +30,12 @@ func TestBitbucketHandler(t *testing.T) {
 		bitbucketUser, err := UserFromContext(ctx)
 		assert.Nil(t, err)
 		assert.Equal(t, expectedUser, bitbucketUser)
+		identity, err := gologin.IdentityFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "bitbucket", identity.Provider)
+		assert.Equal(t, "{4c0a5e86-9d0b-4ad5-8e1f-2c3b7a0f1d2e}", identity.Subject)
+		assert.Equal(t, "bitster", identity.Username)
+		assert.Equal(t, "Atlas Ian", identity.Name)
 		fmt.Fprintf(w, "success handler called")
 	}
 	failure := testutils.AssertFailureNotCalled(t)
@@ -98,11 +104,36 @@ func TestBitbucketHandler_ErrorGettingUser(t *testing.T) {
 }
 
 func TestValidateResponse(t *testing.T) {
-	validUser := &User{Username: "bitster"}
+	validUser := &User{UUID: "{4c0a5e86-9d0b-4ad5-8e1f-2c3b7a0f1d2e}"}
 	validResponse := &http.Response{StatusCode: 200}
 	invalidResponse := &http.Response{StatusCode: 500}
 	assert.Equal(t, nil, validateResponse(validUser, validResponse, nil))
 	assert.Equal(t, ErrUnableToGetBitbucketUser, validateResponse(validUser, validResponse, fmt.Errorf("Server error")))
 	assert.Equal(t, ErrUnableToGetBitbucketUser, validateResponse(validUser, invalidResponse, nil))
 	assert.Equal(t, ErrUnableToGetBitbucketUser, validateResponse(&User{}, validResponse, nil))
+	// usernames may be reused, so users must have a UUID
+	assert.Equal(t, ErrUnableToGetBitbucketUser, validateResponse(&User{Username: "bitster"}, validResponse, nil))
+}
+
+func TestNewProvider_PKCE(t *testing.T) {
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL: "https://bitbucket.org/site/oauth2/authorize",
+		},
+	}
+	registry := gologin.NewRegistry()
+	registry.Register("bitbucket", NewProvider(config, gologin.DebugOnlyCookieConfig, &oauth2Login.ProviderOptions{PKCE: true}))
+	mux := http.NewServeMux()
+	registry.Mount(mux, testutils.AssertSuccessNotCalled(t), testutils.AssertFailureNotCalled(t))
+
+	// Registry mounts the Bitbucket Provider with PKCE, assert that:
+	// - login route redirects to the Bitbucket AuthURL with an S256 code challenge
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth/bitbucket/login", nil)
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	location := w.Result().Header.Get("Location")
+	assert.Contains(t, location, "https://bitbucket.org/site/oauth2/authorize?")
+	assert.Contains(t, location, "code_challenge_method=S256")
 }
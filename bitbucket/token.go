@@ -0,0 +1,21 @@
+package bitbucket
+
+import (
+	"net/http"
+
+	oauth2Login "github.com/dghubble/gologin/v2/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// TokenHandler receives a Bitbucket access token obtained by a native or
+// single-page client (see oauth2 TokenHandler) and gets the corresponding
+// User. If successful, the Token and User are added to the ctx and the
+// success handler is called. Otherwise, the failure handler is called.
+//
+// Bitbucket has no endpoint to check which consumer a token was issued to,
+// so a token issued to another app for the same user is accepted. Only use
+// it with clients you trust to send tokens issued to your own consumer.
+func TokenHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	success = bitbucketHandler(config, success, failure)
+	return oauth2Login.TokenHandler(success, failure)
+}
@@ -0,0 +1,68 @@
+package bitbucket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/gologin/v2"
+	oauth2Login "github.com/dghubble/gologin/v2/oauth2"
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestTokenHandler(t *testing.T) {
+	proxyClient, server := newBitbucketTestServer(`{"uuid": "{4c0a5e86-9d0b-4ad5-8e1f-2c3b7a0f1d2e}", "username": "atlassian_tutorial", "display_name": "Atlassian Tutorial"}`)
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	config := &oauth2.Config{}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "any-token", token.AccessToken)
+		bitbucketUser, err := UserFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "atlassian_tutorial", bitbucketUser.Username)
+		identity, err := gologin.IdentityFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "{4c0a5e86-9d0b-4ad5-8e1f-2c3b7a0f1d2e}", identity.Subject)
+		assert.Equal(t, "atlassian_tutorial", identity.Username)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// TokenHandler with an access_token form field, assert that:
+	// - the User is obtained with the token
+	// - success handler is called with the Token and User
+	handler := TokenHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader("access_token=any-token"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTokenHandler_ErrorGettingUser(t *testing.T) {
+	proxyClient, server := testutils.NewErrorServer("Unauthorized", http.StatusUnauthorized)
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	config := &oauth2.Config{}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrUnableToGetBitbucketUser, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// TokenHandler with a token the Bitbucket API rejects, assert that:
+	// - failure handler is called
+	handler := TokenHandler(config, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer invalid-token")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
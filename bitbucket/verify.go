@@ -9,7 +9,12 @@ import (
 const bitbucketAPI = "https://bitbucket.org/api/2.0/"
 
 // User is a Bitbucket user.
+//
+// Note that usernames may be changed and reused. Use the UUID to identify
+// users.
 type User struct {
+	UUID        string `json:"uuid"`
+	AccountID   string `json:"account_id"`
 	Username    string `json:"username"`
 	DisplayName string `json:"display_name"`
 	Website     string `json:"website"`
@@ -1,6 +1,13 @@
 package gologin
 
-import "net/http"
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrInvalidCookie is returned when a signed or encrypted cookie value cannot
+// be verified or decrypted, or has expired.
+var ErrInvalidCookie = errors.New("gologin: invalid cookie")
 
 // CookieConfig configures http.Cookie creation.
 type CookieConfig struct {
@@ -26,6 +33,16 @@ type CookieConfig struct {
 	// SameSite attribute modes indicates that a browser not send a cookie in
 	// cross-site requests.
 	SameSite http.SameSite
+	// SigningKeys optionally sign cookie values with HMAC-SHA256 so tampered
+	// or expired values are rejected. The first key signs new cookies and all
+	// keys are tried to verify, which allows keys to be rotated.
+	SigningKeys [][]byte
+	// EncryptionKeys optionally encrypt cookie values with AES-GCM so values
+	// (e.g. OAuth1 request secrets) are not readable by browsers or proxies.
+	// Keys must be 16, 24, or 32 bytes (handlers panic when built with other
+	// sizes). The first key encrypts new cookies and all keys are tried to
+	// decrypt, which allows keys to be rotated.
+	EncryptionKeys [][]byte
 }
 
 // DefaultCookieConfig configures short-lived temporary http.Cookie creation.
@@ -10,6 +10,8 @@ type key int
 
 const (
 	errorKey key = iota
+	identityKey
+	providerNameKey
 )
 
 // WithError returns a copy of ctx that stores the given error value.
@@ -26,3 +28,31 @@ func ErrorFromContext(ctx context.Context) error {
 	}
 	return err
 }
+
+// WithIdentity returns a copy of ctx that stores the Identity.
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey, identity)
+}
+
+// IdentityFromContext returns the Identity from the ctx.
+func IdentityFromContext(ctx context.Context) (*Identity, error) {
+	identity, ok := ctx.Value(identityKey).(*Identity)
+	if !ok {
+		return nil, fmt.Errorf("Context missing Identity")
+	}
+	return identity, nil
+}
+
+// WithProviderName returns a copy of ctx that stores the provider name.
+func WithProviderName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, providerNameKey, name)
+}
+
+// ProviderNameFromContext returns the provider name from the ctx.
+func ProviderNameFromContext(ctx context.Context) (string, error) {
+	name, ok := ctx.Value(providerNameKey).(string)
+	if !ok {
+		return "", fmt.Errorf("Context missing provider name")
+	}
+	return name, nil
+}
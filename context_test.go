@@ -21,3 +21,34 @@ func TestErrorFromContext_Error(t *testing.T) {
 		assert.Equal(t, "Context missing error value", err.Error())
 	}
 }
+
+func TestContextIdentity(t *testing.T) {
+	expectedIdentity := &Identity{Provider: "github", Subject: "917408", Name: "Alyssa Hacker"}
+	ctx := WithIdentity(context.Background(), expectedIdentity)
+	identity, err := IdentityFromContext(ctx)
+	assert.Equal(t, expectedIdentity, identity)
+	assert.Nil(t, err)
+}
+
+func TestIdentityFromContext_Error(t *testing.T) {
+	identity, err := IdentityFromContext(context.Background())
+	assert.Nil(t, identity)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "Context missing Identity", err.Error())
+	}
+}
+
+func TestContextProviderName(t *testing.T) {
+	ctx := WithProviderName(context.Background(), "github")
+	name, err := ProviderNameFromContext(ctx)
+	assert.Equal(t, "github", name)
+	assert.Nil(t, err)
+}
+
+func TestProviderNameFromContext_Error(t *testing.T) {
+	name, err := ProviderNameFromContext(context.Background())
+	assert.Equal(t, "", name)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "Context missing provider name", err.Error())
+	}
+}
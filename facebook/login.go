@@ -42,6 +42,20 @@ func CallbackHandler(config *oauth2.Config, success, failure http.Handler) http.
 	return oauth2Login.CallbackHandler(config, success, failure)
 }
 
+// NewProvider returns a gologin.Provider for mounting the Facebook StateHandler,
+// LoginHandler, and CallbackHandler on a gologin.Registry. The options may
+// enable PKCE (nil for defaults).
+func NewProvider(config *oauth2.Config, stateConfig gologin.CookieConfig, options *oauth2Login.ProviderOptions) gologin.Provider {
+	return gologin.ProviderFuncs{
+		Login: func(failure http.Handler) http.Handler {
+			return oauth2Login.ProviderHandler(stateConfig, options, LoginHandler(config, failure))
+		},
+		Callback: func(success, failure http.Handler) http.Handler {
+			return oauth2Login.ProviderHandler(stateConfig, options, CallbackHandler(config, success, failure))
+		},
+	}
+}
+
 // facebookHandler is a http.Handler that gets the OAuth2 Token from the ctx
 // to get the corresponding Facebook User. If successful, the user is added to
 // the ctx and the success handler is called. Otherwise, the failure handler
@@ -68,6 +82,7 @@ func facebookHandler(config *oauth2.Config, success, failure http.Handler) http.
 			return
 		}
 		ctx = WithUser(ctx, user)
+		ctx = gologin.WithIdentity(ctx, newIdentity(user))
 		success.ServeHTTP(w, req.WithContext(ctx))
 	}
 	return http.HandlerFunc(fn)
@@ -84,3 +99,15 @@ func validateResponse(user *User, resp *http.Response, err error) error {
 	}
 	return nil
 }
+
+// newIdentity returns the gologin Identity of the Facebook User.
+func newIdentity(user *User) *gologin.Identity {
+	return &gologin.Identity{
+		Provider:  "facebook",
+		Subject:   user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		AvatarURL: user.Picture.Data.URL,
+		Raw:       user,
+	}
+}
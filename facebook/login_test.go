@@ -30,6 +30,11 @@ func TestFacebookHandler(t *testing.T) {
 		facebookUser, err := UserFromContext(ctx)
 		assert.Nil(t, err)
 		assert.Equal(t, expectedUser, facebookUser)
+		identity, err := gologin.IdentityFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "facebook", identity.Provider)
+		assert.Equal(t, "54638001", identity.Subject)
+		assert.Equal(t, "Ivy Crimson", identity.Name)
 		fmt.Fprintf(w, "success handler called")
 	}
 	failure := testutils.AssertFailureNotCalled(t)
@@ -104,3 +109,26 @@ func TestValidateResponse(t *testing.T) {
 	assert.Error(t, validateResponse(validUser, invalidResponse, nil))
 	assert.Equal(t, ErrUnableToGetFacebookUser, validateResponse(&User{}, validResponse, nil))
 }
+
+func TestNewProvider_PKCE(t *testing.T) {
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL: "https://www.facebook.com/dialog/oauth",
+		},
+	}
+	registry := gologin.NewRegistry()
+	registry.Register("facebook", NewProvider(config, gologin.DebugOnlyCookieConfig, &oauth2Login.ProviderOptions{PKCE: true}))
+	mux := http.NewServeMux()
+	registry.Mount(mux, testutils.AssertSuccessNotCalled(t), testutils.AssertFailureNotCalled(t))
+
+	// Registry mounts the Facebook Provider with PKCE, assert that:
+	// - login route redirects to the Facebook AuthURL with an S256 code challenge
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth/facebook/login", nil)
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	location := w.Result().Header.Get("Location")
+	assert.Contains(t, location, "https://www.facebook.com/dialog/oauth?")
+	assert.Contains(t, location, "code_challenge_method=S256")
+}
@@ -0,0 +1,62 @@
+package facebook
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin/v2"
+	oauth2Login "github.com/dghubble/gologin/v2/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// Facebook access token errors
+var (
+	ErrInvalidToken          = errors.New("facebook: invalid access token")
+	ErrTokenAudienceMismatch = errors.New("facebook: access token was issued to another app")
+)
+
+// TokenHandler receives a Facebook access token obtained by a native or
+// single-page client (see oauth2 TokenHandler) and checks with Facebook's
+// debug_token endpoint (authenticated with an app access token of the Config
+// ClientID and ClientSecret) that it was issued to the app, so tokens issued
+// to other apps can't be substituted. The corresponding User is then
+// obtained. If successful, the Token and User are added to the ctx and the
+// success handler is called. Otherwise, the failure handler is called.
+func TokenHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	success = facebookHandler(config, success, failure)
+	success = audienceHandler(config, success, failure)
+	return oauth2Login.TokenHandler(success, failure)
+}
+
+// audienceHandler is a http.Handler that checks the OAuth2 Token from the ctx
+// was issued to the Facebook app of the Config. If so, the success handler is
+// called. Otherwise, the failure handler is called.
+func audienceHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		facebookService := newClient(oauth2.NewClient(ctx, nil))
+		appToken := config.ClientID + "|" + config.ClientSecret
+		debug, resp, err := facebookService.DebugToken(token.AccessToken, appToken)
+		if err != nil || resp.StatusCode != http.StatusOK || !debug.IsValid {
+			ctx = gologin.WithError(ctx, ErrInvalidToken)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if debug.AppID != config.ClientID {
+			ctx = gologin.WithError(ctx, ErrTokenAudienceMismatch)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,104 @@
+package facebook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin/v2"
+	oauth2Login "github.com/dghubble/gologin/v2/oauth2"
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// newDebugTokenTestServer returns a new httptest.Server which mocks the
+// Facebook debug_token and user endpoints and a client which proxies requests
+// to the server. Valid tokens are reported as issued to the appID. The caller
+// must close the server.
+func newDebugTokenTestServer(t *testing.T, appID string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/v2.9/debug_token", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "client_id|client_secret", req.URL.Query().Get("access_token"))
+		w.Header().Set("Content-Type", "application/json")
+		if req.URL.Query().Get("input_token") != "any-token" {
+			fmt.Fprint(w, `{"data": {"is_valid": false}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"data": {"app_id": %q, "is_valid": true, "user_id": "54638001"}}`, appID)
+	})
+	mux.HandleFunc("/v2.9/me", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer any-token", req.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id": "54638001", "name": "Ivy Crimson"}`)
+	})
+	return client, server
+}
+
+func TestTokenHandler(t *testing.T) {
+	proxyClient, server := newDebugTokenTestServer(t, "client_id")
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	config := &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "any-token", token.AccessToken)
+		facebookUser, err := UserFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "54638001", facebookUser.ID)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// TokenHandler with a Bearer token, assert that:
+	// - the token is inspected with debug_token
+	// - success handler is called with the Token and User
+	handler := TokenHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer any-token")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTokenHandler_Errors(t *testing.T) {
+	proxyClient, server := newDebugTokenTestServer(t, "other_client_id")
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	config := &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+	}
+
+	cases := []struct {
+		authorization string
+		expectedErr   error
+	}{
+		{"", oauth2Login.ErrMissingToken},
+		{"Bearer invalid-token", ErrInvalidToken},
+		{"Bearer any-token", ErrTokenAudienceMismatch},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.expectedErr, gologin.ErrorFromContext(req.Context()))
+			fmt.Fprintf(w, "failure handler called")
+		}
+		// TokenHandler with a missing, invalid, or other app's token, assert that:
+		// - failure handler is called with the error
+		handler := TokenHandler(config, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		if c.authorization != "" {
+			req.Header.Set("Authorization", c.authorization)
+		}
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String())
+	}
+}
@@ -44,3 +44,24 @@ func (c *client) Me() (*User, *http.Response, error) {
 	resp, err := c.sling.New().Set("Accept", "application/json").Get("me?fields=name,email,picture").ReceiveSuccess(user)
 	return user, resp, err
 }
+
+// debugToken is the inspected metadata of an access token.
+type debugToken struct {
+	AppID   string `json:"app_id"`
+	UserID  string `json:"user_id"`
+	IsValid bool   `json:"is_valid"`
+}
+
+// DebugToken inspects the input access token with an app access token.
+// https://developers.facebook.com/docs/graph-api/reference/v2.9/debug_token
+func (c *client) DebugToken(inputToken, appToken string) (*debugToken, *http.Response, error) {
+	params := &struct {
+		InputToken  string `url:"input_token"`
+		AccessToken string `url:"access_token"`
+	}{inputToken, appToken}
+	body := new(struct {
+		Data debugToken `json:"data"`
+	})
+	resp, err := c.sling.New().Set("Accept", "application/json").Get("debug_token").QueryStruct(params).ReceiveSuccess(body)
+	return &body.Data, resp, err
+}
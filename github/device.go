@@ -0,0 +1,27 @@
+package github
+
+import (
+	"context"
+
+	oauth2Login "github.com/dghubble/gologin/v2/oauth2"
+	"github.com/google/go-github/v64/github"
+	"golang.org/x/oauth2"
+)
+
+// DeviceLogin obtains a GitHub access token with the OAuth2 Device
+// Authorization Grant (e.g. for CLIs) and gets the GitHub User, like
+// CallbackHandler. The prompt shows the user where to enter the user code
+// (i.e. https://github.com/login/device). The Config Endpoint must have a
+// DeviceAuthURL, as golang.org/x/oauth2/github Endpoint does, and the OAuth
+// App must have device flow enabled.
+func DeviceLogin(ctx context.Context, config *oauth2.Config, prompt oauth2Login.DevicePrompt) (*oauth2.Token, *github.User, error) {
+	token, err := oauth2Login.DeviceLogin(ctx, config, prompt)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := getUser(ctx, config, token, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return token, user, nil
+}
@@ -0,0 +1,53 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/google/go-github/v64/github"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+	githubOAuth2 "golang.org/x/oauth2/github"
+)
+
+func TestDeviceLogin(t *testing.T) {
+	client, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/login/device/code", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"device_code":"device_code_val","user_code":"WDJB-MJHT","verification_uri":"https://github.com/login/device","expires_in":60,"interval":1}`)
+	})
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "device_code_val", req.PostFormValue("device_code"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"access_token_val","token_type":"bearer"}`)
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer access_token_val", req.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id": 917408, "name": "Alyssa Hacker"}`)
+	})
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: githubOAuth2.Endpoint,
+	}
+	config.Endpoint.AuthStyle = oauth2.AuthStyleInParams
+
+	// DeviceLogin, assert that:
+	// - the user is prompted with the user code
+	// - the access token and GitHub User are returned
+	var userCode string
+	prompt := func(auth *oauth2.DeviceAuthResponse) error {
+		userCode = auth.UserCode
+		return nil
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+	token, user, err := DeviceLogin(ctx, config, prompt)
+	assert.Nil(t, err)
+	assert.Equal(t, "WDJB-MJHT", userCode)
+	assert.Equal(t, "access_token_val", token.AccessToken)
+	assert.Equal(t, &github.User{ID: github.Int64(917408), Name: github.String("Alyssa Hacker")}, user)
+}
@@ -1,10 +1,12 @@
 package github
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 
 	"github.com/dghubble/gologin/v2"
 	oauth2Login "github.com/dghubble/gologin/v2/oauth2"
@@ -43,6 +45,20 @@ func CallbackHandler(config *oauth2.Config, success, failure http.Handler) http.
 	return oauth2Login.CallbackHandler(config, success, failure)
 }
 
+// NewProvider returns a gologin.Provider for mounting the GitHub StateHandler,
+// LoginHandler, and CallbackHandler on a gologin.Registry. The options may
+// enable PKCE (nil for defaults).
+func NewProvider(config *oauth2.Config, stateConfig gologin.CookieConfig, options *oauth2Login.ProviderOptions) gologin.Provider {
+	return gologin.ProviderFuncs{
+		Login: func(failure http.Handler) http.Handler {
+			return oauth2Login.ProviderHandler(stateConfig, options, LoginHandler(config, failure))
+		},
+		Callback: func(success, failure http.Handler) http.Handler {
+			return oauth2Login.ProviderHandler(stateConfig, options, CallbackHandler(config, success, failure))
+		},
+	}
+}
+
 // EnterpriseCallbackHandler handles GitHub Enterprise redirection URI requests
 // and adds the GitHub access token and User to the ctx. If authentication
 // succeeds,handling delegates to the success handler, otherwise to the failure
@@ -70,31 +86,37 @@ func githubHandler(config *oauth2.Config, isEnterprise bool, success, failure ht
 			return
 		}
 
-		httpClient := config.Client(ctx, token)
-		var githubClient *github.Client
-		if isEnterprise {
-			githubClient, err = enterpriseGithubClientFromAuthURL(config.Endpoint.AuthURL, httpClient)
-			if err != nil {
-				ctx = gologin.WithError(ctx, fmt.Errorf("github: error creating Client: %v", err))
-				failure.ServeHTTP(w, req.WithContext(ctx))
-				return
-			}
-		} else {
-			githubClient = github.NewClient(httpClient)
-		}
-		user, resp, err := githubClient.Users.Get(ctx, "")
-		err = validateResponse(user, resp, err)
+		user, err := getUser(ctx, config, token, isEnterprise)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
 		ctx = WithUser(ctx, user)
+		ctx = gologin.WithIdentity(ctx, newIdentity(user))
 		success.ServeHTTP(w, req.WithContext(ctx))
 	}
 	return http.HandlerFunc(fn)
 }
 
+// getUser gets the GitHub User of the Token.
+func getUser(ctx context.Context, config *oauth2.Config, token *oauth2.Token, isEnterprise bool) (*github.User, error) {
+	httpClient := config.Client(ctx, token)
+	githubClient := github.NewClient(httpClient)
+	if isEnterprise {
+		var err error
+		githubClient, err = enterpriseGithubClientFromAuthURL(config.Endpoint.AuthURL, httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("github: error creating Client: %v", err)
+		}
+	}
+	user, resp, err := githubClient.Users.Get(ctx, "")
+	if err := validateResponse(user, resp, err); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
 // validateResponse returns an error if the given GitHub user, raw
 // http.Response, or error are unexpected. Returns nil if they are valid.
 func validateResponse(user *github.User, resp *github.Response, err error) error {
@@ -123,3 +145,16 @@ func enterpriseGithubClientFromAuthURL(authURL string, httpClient *http.Client)
 
 	return client, nil
 }
+
+// newIdentity returns the gologin Identity of the GitHub User.
+func newIdentity(user *github.User) *gologin.Identity {
+	return &gologin.Identity{
+		Provider:  "github",
+		Subject:   strconv.FormatInt(user.GetID(), 10),
+		Email:     user.GetEmail(),
+		Name:      user.GetName(),
+		Username:  user.GetLogin(),
+		AvatarURL: user.GetAvatarURL(),
+		Raw:       user,
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/dghubble/gologin/v2"
@@ -32,6 +33,11 @@ func TestGithubHandler(t *testing.T) {
 		githubUser, err := UserFromContext(ctx)
 		assert.Nil(t, err)
 		assert.Equal(t, expectedUser, githubUser)
+		identity, err := gologin.IdentityFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "github", identity.Provider)
+		assert.Equal(t, "917408", identity.Subject)
+		assert.Equal(t, "Alyssa Hacker", identity.Name)
 		fmt.Fprintf(w, "success handler called")
 	}
 	failure := testutils.AssertFailureNotCalled(t)
@@ -157,3 +163,60 @@ func Test_enterpriseGithubClientFromAuthURL(t *testing.T) {
 		assert.Equal(t, client.BaseURL.String(), c.expClientBaseURL)
 	}
 }
+
+func TestNewProvider(t *testing.T) {
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL: "https://github.com/login/oauth/authorize",
+		},
+	}
+	registry := gologin.NewRegistry()
+	registry.Register("github", NewProvider(config, gologin.DebugOnlyCookieConfig, nil))
+	mux := http.NewServeMux()
+	registry.Mount(mux, testutils.AssertSuccessNotCalled(t), testutils.AssertFailureNotCalled(t))
+
+	// Registry mounts the GitHub Provider, assert that:
+	// - login route issues a state cookie and a provider cookie
+	// - login route redirects to the GitHub AuthURL
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth/github/login", nil)
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Contains(t, w.Result().Header.Get("Location"), "https://github.com/login/oauth/authorize?client_id=client_id")
+	assert.NotContains(t, w.Result().Header.Get("Location"), "code_challenge")
+	if cookies := w.Result().Cookies(); assert.Len(t, cookies, 2) {
+		assert.Equal(t, "github", cookies[1].Value)
+	}
+}
+
+func TestNewProvider_PKCE(t *testing.T) {
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL: "https://github.com/login/oauth/authorize",
+		},
+	}
+	registry := gologin.NewRegistry()
+	registry.Register("github", NewProvider(config, gologin.DebugOnlyCookieConfig, &oauth2Login.ProviderOptions{PKCE: true}))
+	mux := http.NewServeMux()
+	registry.Mount(mux, testutils.AssertSuccessNotCalled(t), testutils.AssertFailureNotCalled(t))
+
+	// Registry mounts the GitHub Provider with PKCE, assert that:
+	// - login route issues a PKCE verifier cookie
+	// - login route redirects with an S256 code challenge
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth/github/login", nil)
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Result().Header.Get("Location"))
+	if assert.Nil(t, err) {
+		assert.NotEmpty(t, location.Query().Get("code_challenge"))
+		assert.Equal(t, "S256", location.Query().Get("code_challenge_method"))
+	}
+	var names []string
+	for _, cookie := range w.Result().Cookies() {
+		names = append(names, cookie.Name)
+	}
+	assert.Contains(t, names, gologin.DebugOnlyCookieConfig.Name+"-pkce")
+}
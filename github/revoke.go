@@ -0,0 +1,46 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	oauth2Login "github.com/dghubble/gologin/v2/oauth2"
+	"github.com/google/go-github/v64/github"
+	"golang.org/x/oauth2"
+)
+
+// Revoker returns an oauth2 Revoker which deletes the user's grant of the
+// GitHub OAuth App, revoking all of its Tokens for the user, for use with
+// the oauth2 LogoutHandler. Requests authenticate with the app's client ID
+// and secret and use the http.Client in the ctx under oauth2.HTTPClient, if
+// any.
+func Revoker(config *oauth2.Config) oauth2Login.Revoker {
+	fn := func(ctx context.Context, token *oauth2.Token) error {
+		resp, err := appClient(ctx, config).Authorizations.DeleteGrant(ctx, config.ClientID, token.AccessToken)
+		if err != nil {
+			return fmt.Errorf("github: unable to revoke grant: %v", err)
+		}
+		if resp.StatusCode != http.StatusNoContent {
+			return fmt.Errorf("github: unable to revoke grant: status %d", resp.StatusCode)
+		}
+		return nil
+	}
+	return oauth2Login.RevokerFunc(fn)
+}
+
+// appClient returns a GitHub client which authenticates as the OAuth App
+// with its client ID and secret, using the http.Client in the ctx under
+// oauth2.HTTPClient, if any.
+func appClient(ctx context.Context, config *oauth2.Config) *github.Client {
+	transport := http.DefaultTransport
+	if client, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && client.Transport != nil {
+		transport = client.Transport
+	}
+	basicAuth := &github.BasicAuthTransport{
+		Username:  config.ClientID,
+		Password:  config.ClientSecret,
+		Transport: transport,
+	}
+	return github.NewClient(basicAuth.Client())
+}
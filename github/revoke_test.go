@@ -0,0 +1,46 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestRevoker(t *testing.T) {
+	client, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/applications/client_id/grant", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "DELETE", req.Method)
+		username, password, ok := req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "client_id", username)
+		assert.Equal(t, "client_secret", password)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	config := &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+	}
+
+	// Revoker deletes the app grant with client Basic auth
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+	err := Revoker(config).Revoke(ctx, &oauth2.Token{AccessToken: "access_token_val"})
+	assert.Nil(t, err)
+}
+
+func TestRevoker_Error(t *testing.T) {
+	client, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/applications/client_id/grant", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	config := &oauth2.Config{ClientID: "client_id"}
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+	err := Revoker(config).Revoke(ctx, &oauth2.Token{AccessToken: "access_token_val"})
+	assert.Error(t, err)
+}
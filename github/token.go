@@ -0,0 +1,61 @@
+package github
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dghubble/gologin/v2"
+	oauth2Login "github.com/dghubble/gologin/v2/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// GitHub access token errors
+var (
+	ErrInvalidToken          = errors.New("github: invalid access token")
+	ErrTokenAudienceMismatch = errors.New("github: access token was issued to another app")
+)
+
+// TokenHandler receives a GitHub access token obtained by a native or
+// single-page client (see oauth2 TokenHandler) and checks with GitHub's
+// OAuth App token API (authenticated with the Config ClientID and
+// ClientSecret) that it was issued to the app, so tokens issued to other
+// apps can't be substituted. The corresponding User is then obtained. If
+// successful, the Token and User are added to the ctx and the success
+// handler is called. Otherwise, the failure handler is called.
+func TokenHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	success = githubHandler(config, false, success, failure)
+	success = audienceHandler(config, success, failure)
+	return oauth2Login.TokenHandler(success, failure)
+}
+
+// audienceHandler is a http.Handler that checks the OAuth2 Token from the ctx
+// was issued to the GitHub OAuth App of the Config. If so, the success
+// handler is called. Otherwise, the failure handler is called.
+func audienceHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// checks the token with POST /applications/{client_id}/token
+		auth, resp, err := appClient(ctx, config).Authorizations.Check(ctx, config.ClientID, token.AccessToken)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			ctx = gologin.WithError(ctx, ErrInvalidToken)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if auth.GetApp().GetClientID() != config.ClientID {
+			ctx = gologin.WithError(ctx, ErrTokenAudienceMismatch)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
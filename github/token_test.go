@@ -0,0 +1,116 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin/v2"
+	oauth2Login "github.com/dghubble/gologin/v2/oauth2"
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// newTokenCheckTestServer returns a new httptest.Server which mocks the
+// GitHub OAuth App token check and user endpoints and a client which proxies
+// requests to the server. Checked tokens are reported as issued to the
+// appClientID. The caller must close the server.
+func newTokenCheckTestServer(t *testing.T, appClientID string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/applications/client_id/token", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "POST", req.Method)
+		username, password, ok := req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "client_id", username)
+		assert.Equal(t, "client_secret", password)
+		var body struct {
+			AccessToken string `json:"access_token"`
+		}
+		json.NewDecoder(req.Body).Decode(&body)
+		if body.AccessToken != "any-token" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": 1, "app": {"client_id": %q}, "user": {"id": 917408}}`, appClientID)
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer any-token", req.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id": 917408, "name": "Alyssa Hacker"}`)
+	})
+	return client, server
+}
+
+func TestTokenHandler(t *testing.T) {
+	proxyClient, server := newTokenCheckTestServer(t, "client_id")
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	config := &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "any-token", token.AccessToken)
+		githubUser, err := UserFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(917408), githubUser.GetID())
+		identity, err := gologin.IdentityFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "917408", identity.Subject)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// TokenHandler with a Bearer token, assert that:
+	// - the token is checked with the OAuth App token API
+	// - success handler is called with the Token and User
+	handler := TokenHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer any-token")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTokenHandler_Errors(t *testing.T) {
+	proxyClient, server := newTokenCheckTestServer(t, "other_client_id")
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	config := &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+	}
+
+	cases := []struct {
+		authorization string
+		expectedErr   error
+	}{
+		{"", oauth2Login.ErrMissingToken},
+		{"Bearer invalid-token", ErrInvalidToken},
+		{"Bearer any-token", ErrTokenAudienceMismatch},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.expectedErr, gologin.ErrorFromContext(req.Context()))
+			fmt.Fprintf(w, "failure handler called")
+		}
+		// TokenHandler with a missing, invalid, or other app's token, assert that:
+		// - failure handler is called with the error
+		handler := TokenHandler(config, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		if c.authorization != "" {
+			req.Header.Set("Authorization", c.authorization)
+		}
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String())
+	}
+}
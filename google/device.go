@@ -0,0 +1,27 @@
+package google
+
+import (
+	"context"
+
+	oauth2Login "github.com/dghubble/gologin/v2/oauth2"
+	"golang.org/x/oauth2"
+	google "google.golang.org/api/oauth2/v2"
+)
+
+// DeviceLogin obtains a Google access token with the OAuth2 Device
+// Authorization Grant (e.g. for CLIs or TVs) and gets the Google Userinfo,
+// like CallbackHandler. The prompt shows the user where to enter the user
+// code (i.e. https://www.google.com/device). The Config Endpoint must have
+// a DeviceAuthURL, as golang.org/x/oauth2/google Endpoint does, and the
+// client must be a "TVs and Limited Input devices" client.
+func DeviceLogin(ctx context.Context, config *oauth2.Config, prompt oauth2Login.DevicePrompt) (*oauth2.Token, *google.Userinfo, error) {
+	token, err := oauth2Login.DeviceLogin(ctx, config, prompt)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := getUser(ctx, config, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	return token, user, nil
+}
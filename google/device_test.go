@@ -0,0 +1,53 @@
+package google
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+	googleOAuth2 "golang.org/x/oauth2/google"
+)
+
+func TestDeviceLogin(t *testing.T) {
+	client, mux, server := testutils.TestServer()
+	defer server.Close()
+	mux.HandleFunc("/device/code", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"device_code":"device_code_val","user_code":"GQVQ-JKEC","verification_url":"https://www.google.com/device","expires_in":60,"interval":1}`)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "device_code_val", req.PostFormValue("device_code"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"access_token_val","token_type":"Bearer"}`)
+	})
+	mux.HandleFunc("/oauth2/v2/userinfo", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer access_token_val", req.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id": "900913", "name": "Ben Bitdiddle"}`)
+	})
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: googleOAuth2.Endpoint,
+	}
+
+	// DeviceLogin, assert that:
+	// - the user is prompted with the verification URL and user code
+	// - the access token and Google Userinfo are returned
+	var prompted *oauth2.DeviceAuthResponse
+	prompt := func(auth *oauth2.DeviceAuthResponse) error {
+		prompted = auth
+		return nil
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+	token, user, err := DeviceLogin(ctx, config, prompt)
+	assert.Nil(t, err)
+	assert.Equal(t, "GQVQ-JKEC", prompted.UserCode)
+	assert.Equal(t, "https://www.google.com/device", prompted.VerificationURI)
+	assert.Equal(t, "access_token_val", token.AccessToken)
+	assert.Equal(t, "900913", user.Id)
+	assert.Equal(t, "Ben Bitdiddle", user.Name)
+}
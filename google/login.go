@@ -1,6 +1,7 @@
 package google
 
 import (
+	"context"
 	"errors"
 	"net/http"
 
@@ -43,6 +44,20 @@ func CallbackHandler(config *oauth2.Config, success, failure http.Handler) http.
 	return oauth2Login.CallbackHandler(config, success, failure)
 }
 
+// NewProvider returns a gologin.Provider for mounting the Google StateHandler,
+// LoginHandler, and CallbackHandler on a gologin.Registry. The options may
+// enable PKCE (nil for defaults).
+func NewProvider(config *oauth2.Config, stateConfig gologin.CookieConfig, options *oauth2Login.ProviderOptions) gologin.Provider {
+	return gologin.ProviderFuncs{
+		Login: func(failure http.Handler) http.Handler {
+			return oauth2Login.ProviderHandler(stateConfig, options, LoginHandler(config, failure))
+		},
+		Callback: func(success, failure http.Handler) http.Handler {
+			return oauth2Login.ProviderHandler(stateConfig, options, CallbackHandler(config, success, failure))
+		},
+	}
+}
+
 // googleHandler is a http.Handler that gets the OAuth2 Token from the ctx
 // to get the corresponding Google Userinfo. If successful, the user info
 // is added to the ctx and the success handler is called. Otherwise, the
@@ -59,26 +74,33 @@ func googleHandler(config *oauth2.Config, success, failure http.Handler) http.Ha
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
-		httpClient := config.Client(ctx, token)
-		googleService, err := google.NewService(ctx, option.WithHTTPClient(httpClient))
-		if err != nil {
-			ctx = gologin.WithError(ctx, err)
-			failure.ServeHTTP(w, req.WithContext(ctx))
-			return
-		}
-		userInfoPlus, err := googleService.Userinfo.Get().Do()
-		err = validateResponse(userInfoPlus, err)
+		userInfoPlus, err := getUser(ctx, config, token)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
 		ctx = WithUser(ctx, userInfoPlus)
+		ctx = gologin.WithIdentity(ctx, newIdentity(userInfoPlus))
 		success.ServeHTTP(w, req.WithContext(ctx))
 	}
 	return http.HandlerFunc(fn)
 }
 
+// getUser gets the Google Userinfo of the Token.
+func getUser(ctx context.Context, config *oauth2.Config, token *oauth2.Token) (*google.Userinfo, error) {
+	httpClient := config.Client(ctx, token)
+	googleService, err := google.NewService(ctx, option.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, err
+	}
+	userInfoPlus, err := googleService.Userinfo.Get().Do()
+	if err := validateResponse(userInfoPlus, err); err != nil {
+		return nil, err
+	}
+	return userInfoPlus, nil
+}
+
 // validateResponse returns an error if the given Google Userinfo, raw
 // http.Response, or error are unexpected. Returns nil if they are valid.
 func validateResponse(user *google.Userinfo, err error) error {
@@ -90,3 +112,16 @@ func validateResponse(user *google.Userinfo, err error) error {
 	}
 	return nil
 }
+
+// newIdentity returns the gologin Identity of the Google User.
+func newIdentity(user *google.Userinfo) *gologin.Identity {
+	return &gologin.Identity{
+		Provider:      "google",
+		Subject:       user.Id,
+		Email:         user.Email,
+		EmailVerified: user.VerifiedEmail != nil && *user.VerifiedEmail,
+		Name:          user.Name,
+		AvatarURL:     user.Picture,
+		Raw:           user,
+	}
+}
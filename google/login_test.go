@@ -33,6 +33,11 @@ func TestGoogleHandler(t *testing.T) {
 		// assert required fields; Userinfo contains other raw response info
 		assert.Equal(t, expectedUser.Id, googleUser.Id)
 		assert.Equal(t, expectedUser.Id, googleUser.Id)
+		identity, err := gologin.IdentityFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "google", identity.Provider)
+		assert.Equal(t, "900913", identity.Subject)
+		assert.Equal(t, "Ben Bitdiddle", identity.Name)
 		fmt.Fprintf(w, "success handler called")
 	}
 	failure := testutils.AssertFailureNotCalled(t)
@@ -106,3 +111,26 @@ func TestValidateResponse(t *testing.T) {
 	assert.Equal(t, ErrCannotValidateGoogleUser, validateResponse(nil, nil))
 	assert.Equal(t, ErrCannotValidateGoogleUser, validateResponse(&google.Userinfo{Name: "Ben"}, nil))
 }
+
+func TestNewProvider_PKCE(t *testing.T) {
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL: "https://accounts.google.com/o/oauth2/auth",
+		},
+	}
+	registry := gologin.NewRegistry()
+	registry.Register("google", NewProvider(config, gologin.DebugOnlyCookieConfig, &oauth2Login.ProviderOptions{PKCE: true}))
+	mux := http.NewServeMux()
+	registry.Mount(mux, testutils.AssertSuccessNotCalled(t), testutils.AssertFailureNotCalled(t))
+
+	// Registry mounts the Google Provider with PKCE, assert that:
+	// - login route redirects to the Google AuthURL with an S256 code challenge
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth/google/login", nil)
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	location := w.Result().Header.Get("Location")
+	assert.Contains(t, location, "https://accounts.google.com/o/oauth2/auth?")
+	assert.Contains(t, location, "code_challenge_method=S256")
+}
@@ -0,0 +1,15 @@
+package google
+
+import (
+	oauth2Login "github.com/dghubble/gologin/v2/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// RevocationURL is Google's OAuth 2 token revocation endpoint.
+const RevocationURL = "https://oauth2.googleapis.com/revoke"
+
+// Revoker returns an oauth2 Revoker which revokes Google Tokens, for use
+// with the oauth2 LogoutHandler.
+func Revoker(config *oauth2.Config) oauth2Login.Revoker {
+	return oauth2Login.NewRevoker(config, RevocationURL)
+}
@@ -0,0 +1,81 @@
+package google
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/gologin/v2"
+	oauth2Login "github.com/dghubble/gologin/v2/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// tokenInfoURL is Google's endpoint for inspecting access tokens.
+const tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+// Google access token errors
+var (
+	ErrInvalidToken          = errors.New("google: invalid access token")
+	ErrTokenAudienceMismatch = errors.New("google: access token was issued to another client")
+)
+
+// TokenHandler receives a Google access token obtained by a native or
+// single-page client (see oauth2 TokenHandler) and checks with Google's
+// tokeninfo endpoint that it was issued to the Config ClientID, so tokens
+// issued to other apps can't be substituted. The corresponding Userinfo is
+// then obtained. If successful, the Token and Userinfo are added to the ctx
+// and the success handler is called. Otherwise, the failure handler is
+// called.
+func TokenHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	success = googleHandler(config, success, failure)
+	success = audienceHandler(config, success, failure)
+	return oauth2Login.TokenHandler(success, failure)
+}
+
+// audienceHandler is a http.Handler that checks the OAuth2 Token from the ctx
+// was issued to the Config ClientID. If so, the success handler is called.
+// Otherwise, the failure handler is called.
+func audienceHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		if err := checkAudience(oauth2.NewClient(ctx, nil), config.ClientID, token.AccessToken); err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// checkAudience returns an error if the access token is invalid or was not
+// issued to the clientID.
+func checkAudience(client *http.Client, clientID, accessToken string) error {
+	data := url.Values{"access_token": {accessToken}}
+	resp, err := client.Post(tokenInfoURL, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
+	if err != nil {
+		return ErrInvalidToken
+	}
+	defer resp.Body.Close()
+	var info struct {
+		Audience string `json:"aud"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&info) != nil {
+		return ErrInvalidToken
+	}
+	if info.Audience == "" || info.Audience != clientID {
+		return ErrTokenAudienceMismatch
+	}
+	return nil
+}
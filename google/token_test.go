@@ -0,0 +1,109 @@
+package google
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/gologin/v2"
+	oauth2Login "github.com/dghubble/gologin/v2/oauth2"
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// newTokenInfoTestServer returns a new httptest.Server which mocks the Google
+// tokeninfo and Userinfo endpoints and a client which proxies requests to the
+// server. The tokeninfo endpoint responds with the given audience. The
+// caller must close the server.
+func newTokenInfoTestServer(t *testing.T, audience string) (*http.Client, *httptest.Server) {
+	client, mux, server := testutils.TestServer()
+	mux.HandleFunc("/tokeninfo", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "POST", req.Method)
+		if req.PostFormValue("access_token") != "any-token" {
+			http.Error(w, `{"error_description":"Invalid Value"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"azp":%q,"aud":%q,"sub":"900913","expires_in":"3599"}`, audience, audience)
+	})
+	mux.HandleFunc("/oauth2/v2/userinfo", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer any-token", req.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id": "900913", "name": "Ben Bitdiddle"}`)
+	})
+	return client, server
+}
+
+func TestTokenHandler(t *testing.T) {
+	proxyClient, server := newTokenInfoTestServer(t, "client_id")
+	defer server.Close()
+	// oauth2 Client will use the proxy client's base Transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	config := &oauth2.Config{ClientID: "client_id"}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "any-token", token.AccessToken)
+		googleUser, err := UserFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "900913", googleUser.Id)
+		identity, err := gologin.IdentityFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "900913", identity.Subject)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// TokenHandler with a Bearer token, assert that:
+	// - the token audience is checked with tokeninfo
+	// - success handler is called with the Token and Userinfo
+	handler := TokenHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer any-token")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+
+	// TokenHandler with an access_token form field
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/", strings.NewReader("access_token=any-token"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTokenHandler_Errors(t *testing.T) {
+	proxyClient, server := newTokenInfoTestServer(t, "other_client_id")
+	defer server.Close()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, proxyClient)
+	config := &oauth2.Config{ClientID: "client_id"}
+
+	cases := []struct {
+		authorization string
+		expectedErr   error
+	}{
+		{"", oauth2Login.ErrMissingToken},
+		{"Bearer invalid-token", ErrInvalidToken},
+		{"Bearer any-token", ErrTokenAudienceMismatch},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.expectedErr, gologin.ErrorFromContext(req.Context()))
+			fmt.Fprintf(w, "failure handler called")
+		}
+		// TokenHandler with a missing, invalid, or other app's token, assert that:
+		// - failure handler is called with the error
+		handler := TokenHandler(config, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		if c.authorization != "" {
+			req.Header.Set("Authorization", c.authorization)
+		}
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "failure handler called", w.Body.String())
+	}
+}
@@ -0,0 +1,24 @@
+package gologin
+
+// Identity is a provider-independent summary of an authenticated user. Each
+// provider's handlers add an Identity to the ctx alongside the provider's own
+// User type, so apps supporting several providers may handle them alike.
+type Identity struct {
+	// Provider is the provider name (e.g. "github") or, for OpenID Connect,
+	// the issuer URL.
+	Provider string
+	// Subject is the user's stable identifier at the provider.
+	Subject string
+	// Email is the user's email address, if the provider shares it.
+	Email string
+	// EmailVerified indicates the provider verified the Email address.
+	EmailVerified bool
+	// Name is the user's display name.
+	Name string
+	// Username is the user's handle (e.g. GitHub login), if any.
+	Username string
+	// AvatarURL is the URL of the user's profile image, if any.
+	AvatarURL string
+	// Raw is the provider's User type (e.g. *github.User).
+	Raw interface{}
+}
@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin/v2"
+)
+
+// ReadCookie returns the value of the request cookie named by the
+// CookieConfig, verifying and decrypting it if the CookieConfig has signing
+// or encryption keys. If the cookie is missing, http.ErrNoCookie is returned.
+// If it cannot be verified or decrypted or has expired, gologin.ErrInvalidCookie
+// is returned.
+func ReadCookie(req *http.Request, config gologin.CookieConfig) (string, error) {
+	cookie, err := req.Cookie(config.Name)
+	if err != nil {
+		return "", err
+	}
+	return decodeValue(config, cookie.Value, time.Now())
+}
+
+// encodeValue returns the cookie value to issue for the given value. If the
+// CookieConfig has keys, the value is prefixed with the issue time and then
+// encrypted and/or signed, bound to the cookie name.
+func encodeValue(config gologin.CookieConfig, value string) string {
+	if value == "" || (len(config.SigningKeys) == 0 && len(config.EncryptionKeys) == 0) {
+		return value
+	}
+	data := []byte(strconv.FormatInt(time.Now().Unix(), 10) + "|" + value)
+	if len(config.EncryptionKeys) > 0 {
+		aead, err := newAEAD(config.EncryptionKeys[0])
+		if err != nil {
+			// unreachable for configs checked by MustValidConfig, and an
+			// empty value is never accepted by ReadCookie
+			return ""
+		}
+		nonce := make([]byte, aead.NonceSize())
+		rand.Read(nonce)
+		data = aead.Seal(nonce, nonce, data, []byte(config.Name))
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	if len(config.SigningKeys) > 0 {
+		mac := sign(config.SigningKeys[0], config.Name, encoded)
+		encoded = encoded + "." + base64.RawURLEncoding.EncodeToString(mac)
+	}
+	return encoded
+}
+
+// decodeValue verifies and decrypts a cookie value issued by encodeValue and
+// checks it has not outlived the CookieConfig MaxAge.
+func decodeValue(config gologin.CookieConfig, encoded string, now time.Time) (string, error) {
+	if len(config.SigningKeys) == 0 && len(config.EncryptionKeys) == 0 {
+		return encoded, nil
+	}
+	if len(config.SigningKeys) > 0 {
+		payload, macEncoded, ok := strings.Cut(encoded, ".")
+		if !ok {
+			return "", gologin.ErrInvalidCookie
+		}
+		mac, err := base64.RawURLEncoding.DecodeString(macEncoded)
+		if err != nil || !verify(config.SigningKeys, config.Name, payload, mac) {
+			return "", gologin.ErrInvalidCookie
+		}
+		encoded = payload
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", gologin.ErrInvalidCookie
+	}
+	if len(config.EncryptionKeys) > 0 {
+		if data, err = decrypt(config.EncryptionKeys, config.Name, data); err != nil {
+			return "", gologin.ErrInvalidCookie
+		}
+	}
+	issued, value, ok := strings.Cut(string(data), "|")
+	if !ok {
+		return "", gologin.ErrInvalidCookie
+	}
+	issuedAt, err := strconv.ParseInt(issued, 10, 64)
+	if err != nil {
+		return "", gologin.ErrInvalidCookie
+	}
+	if config.MaxAge > 0 && now.After(time.Unix(issuedAt, 0).Add(time.Duration(config.MaxAge)*time.Second)) {
+		return "", gologin.ErrInvalidCookie
+	}
+	return value, nil
+}
+
+// sign returns the HMAC-SHA256 of the cookie name and payload.
+func sign(key []byte, name, payload string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(name + "|" + payload))
+	return h.Sum(nil)
+}
+
+// verify returns true if any key produced the mac.
+func verify(keys [][]byte, name, payload string, mac []byte) bool {
+	for _, key := range keys {
+		if hmac.Equal(mac, sign(key, name, payload)) {
+			return true
+		}
+	}
+	return false
+}
+
+// decrypt opens the nonce-prefixed ciphertext with any of the keys.
+func decrypt(keys [][]byte, name string, data []byte) ([]byte, error) {
+	for _, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			continue
+		}
+		if len(data) < aead.NonceSize() {
+			break
+		}
+		nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+		if plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(name)); err == nil {
+			return plaintext, nil
+		}
+	}
+	return nil, gologin.ErrInvalidCookie
+}
+
+// MustValidConfig panics if the CookieConfig EncryptionKeys are not valid
+// AES keys. Handlers call it when they are built, so an invalid CookieConfig
+// is a startup error rather than a failure of every request.
+func MustValidConfig(config gologin.CookieConfig) {
+	for _, key := range config.EncryptionKeys {
+		if _, err := newAEAD(key); err != nil {
+			panic(fmt.Sprintf("gologin: invalid CookieConfig EncryptionKeys: %v", err))
+		}
+	}
+}
+
+// newAEAD returns an AES-GCM AEAD for the key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodec_BindsCookieName(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	config.SigningKeys = [][]byte{[]byte("signing-key")}
+	cookie := NewCookie(config, "value")
+
+	// a value issued for one cookie name is rejected under another name
+	other := DerivedConfig(config, "pkce")
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: other.Name, Value: cookie.Value})
+	_, err := ReadCookie(req, other)
+	assert.Equal(t, gologin.ErrInvalidCookie, err)
+}
+
+func TestCodec_Expiry(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	config.EncryptionKeys = [][]byte{[]byte("0123456789abcdef0123456789abcdef")}
+	encoded := encodeValue(config, "value")
+
+	value, err := decodeValue(config, encoded, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, "value", value)
+
+	// values older than MaxAge are rejected, even if replayed
+	later := time.Now().Add(time.Duration(config.MaxAge+1) * time.Second)
+	_, err = decodeValue(config, encoded, later)
+	assert.Equal(t, gologin.ErrInvalidCookie, err)
+}
+
+func TestCodec_InvalidEncryptionKey(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	config.EncryptionKeys = [][]byte{[]byte("short")}
+	// invalid keys are rejected when handlers are built
+	assert.Panics(t, func() {
+		MustValidConfig(config)
+	})
+
+	// requests never panic, values just can't be read back
+	assert.NotPanics(t, func() {
+		cookie := NewCookie(config, "value")
+		_, err := decodeValue(config, cookie.Value, time.Now())
+		assert.Equal(t, gologin.ErrInvalidCookie, err)
+	})
+	valid := gologin.DebugOnlyCookieConfig
+	valid.EncryptionKeys = [][]byte{[]byte("0123456789abcdef")}
+	encoded := encodeValue(valid, "value")
+	config.EncryptionKeys = [][]byte{[]byte("short"), []byte("0123456789abcdef")}
+	value, err := decodeValue(config, encoded, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, "value", value)
+}
@@ -8,19 +8,22 @@ import (
 )
 
 // NewCookie returns a new http.Cookie with the given value and CookieConfig
-// properties (name, max-age, etc.).
+// properties (name, max-age, etc.). If the CookieConfig has signing or
+// encryption keys, the value is signed and/or encrypted (see ReadCookie).
+// SameSite None cookies are always Secure, since browsers reject them
+// otherwise.
 //
 // The MaxAge field is used to determine whether an Expires field should be
 // added for Internet Explorer compatibility and what its value should be.
 func NewCookie(config gologin.CookieConfig, value string) *http.Cookie {
 	cookie := &http.Cookie{
 		Name:     config.Name,
-		Value:    value,
+		Value:    encodeValue(config, value),
 		Domain:   config.Domain,
 		Path:     config.Path,
 		MaxAge:   config.MaxAge,
 		HttpOnly: config.HTTPOnly,
-		Secure:   config.Secure,
+		Secure:   config.Secure || config.SameSite == http.SameSiteNoneMode,
 		SameSite: config.SameSite,
 	}
 	// IE <9 does not understand MaxAge, set Expires if MaxAge is non-zero.
@@ -44,3 +47,11 @@ func expiresTime(maxAge int) (time.Time, bool) {
 	}
 	return time.Time{}, false
 }
+
+// DerivedConfig returns a copy of the CookieConfig whose cookie name has the
+// given suffix, so handlers may keep related values (e.g. a PKCE verifier)
+// alongside the state cookie without clobbering it.
+func DerivedConfig(config gologin.CookieConfig, suffix string) gologin.CookieConfig {
+	config.Name = config.Name + "-" + suffix
+	return config
+}
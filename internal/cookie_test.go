@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCookie(t *testing.T) {
+	cookie := NewCookie(gologin.DefaultCookieConfig, "value")
+	assert.Equal(t, "gologin-temporary-cookie", cookie.Name)
+	assert.Equal(t, "value", cookie.Value)
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+}
+
+func TestNewCookie_SameSiteNone(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	config.SameSite = http.SameSiteNoneMode
+
+	// SameSite None cookies are always Secure
+	cookie := NewCookie(config, "value")
+	assert.Equal(t, http.SameSiteNoneMode, cookie.SameSite)
+	assert.True(t, cookie.Secure)
+}
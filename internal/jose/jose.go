@@ -0,0 +1,323 @@
+// Package jose provides the minimal JSON Web Signature and JSON Web Key
+// support needed to sign and verify compact serialized JWTs.
+package jose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256" // register SHA-256 for crypto.Hash
+	_ "crypto/sha512" // register SHA-384 and SHA-512 for crypto.Hash
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Errors which may occur parsing or verifying a JWT.
+var (
+	ErrMalformed          = errors.New("jose: malformed JWT")
+	ErrUnsupportedAlg     = errors.New("jose: unsupported signing algorithm")
+	ErrInvalidSignature   = errors.New("jose: invalid JWT signature")
+	ErrUnsupportedKeyType = errors.New("jose: unsupported JSON Web Key type")
+	ErrAlgMismatch        = errors.New("jose: JWT algorithm does not match the key")
+)
+
+// Header is a JWS protected header.
+type Header struct {
+	Algorithm string      `json:"alg"`
+	KeyID     string      `json:"kid,omitempty"`
+	Type      string      `json:"typ,omitempty"`
+	JWK       *JSONWebKey `json:"jwk,omitempty"`
+}
+
+// JWT is a parsed, but not yet verified, compact serialized JWT.
+type JWT struct {
+	Header    Header
+	Payload   []byte
+	signed    string
+	signature []byte
+}
+
+// Parse parses a compact serialized JWT. The signature is NOT verified.
+func Parse(raw string) (*JWT, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	jwt := &JWT{
+		Payload:   payload,
+		signed:    parts[0] + "." + parts[1],
+		signature: signature,
+	}
+	if err := json.Unmarshal(headerJSON, &jwt.Header); err != nil {
+		return nil, ErrMalformed
+	}
+	return jwt, nil
+}
+
+// Claims unmarshals the JWT payload into v.
+func (t *JWT) Claims(v interface{}) error {
+	if err := json.Unmarshal(t.Payload, v); err != nil {
+		return fmt.Errorf("jose: invalid JWT claims: %v", err)
+	}
+	return nil
+}
+
+// Verify checks the JWT signature with the given public key, using the
+// algorithm named in the header. The algorithm must suit the key: RSA keys
+// verify "RS" and "PS" algorithms, while EC keys only verify the "ES"
+// algorithm of their curve. The "none" algorithm is never accepted.
+func (t *JWT) Verify(key crypto.PublicKey) error {
+	hash, err := algHash(t.Header.Algorithm)
+	if err != nil {
+		return err
+	}
+	if !keyAllows(key, t.Header.Algorithm) {
+		return ErrAlgMismatch
+	}
+	h := hash.New()
+	h.Write([]byte(t.signed))
+	digest := h.Sum(nil)
+
+	switch t.Header.Algorithm[:2] {
+	case "RS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrInvalidSignature
+		}
+		if rsa.VerifyPKCS1v15(pub, hash, digest, t.signature) != nil {
+			return ErrInvalidSignature
+		}
+	case "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrInvalidSignature
+		}
+		opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+		if rsa.VerifyPSS(pub, hash, digest, t.signature, opts) != nil {
+			return ErrInvalidSignature
+		}
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrInvalidSignature
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(t.signature) != 2*size {
+			return ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(t.signature[:size])
+		s := new(big.Int).SetBytes(t.signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return ErrInvalidSignature
+		}
+	}
+	return nil
+}
+
+// Sign returns a compact serialized JWT of the claims signed by the key. The
+// header algorithm is chosen from the key type if it is not set.
+func Sign(key crypto.Signer, header Header, claims interface{}) (string, error) {
+	if header.Algorithm == "" {
+		alg, err := KeyAlgorithm(key.Public())
+		if err != nil {
+			return "", err
+		}
+		header.Algorithm = alg
+	}
+	hash, err := algHash(header.Algorithm)
+	if err != nil {
+		return "", err
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var signature []byte
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		if err != nil {
+			return "", err
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+	default:
+		var opts crypto.SignerOpts = hash
+		if strings.HasPrefix(header.Algorithm, "PS") {
+			opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+		}
+		signature, err = key.Sign(rand.Reader, digest, opts)
+		if err != nil {
+			return "", err
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// KeyAlgorithm returns the default JWS algorithm for a public key.
+func KeyAlgorithm(key crypto.PublicKey) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return "RS256", nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return "ES256", nil
+		case elliptic.P384():
+			return "ES384", nil
+		case elliptic.P521():
+			return "ES512", nil
+		}
+	}
+	return "", ErrUnsupportedKeyType
+}
+
+// keyAllows returns true if the public key may verify signatures made with
+// the algorithm.
+func keyAllows(key crypto.PublicKey, alg string) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		// ES algorithms are bound to a curve
+		keyAlg, err := KeyAlgorithm(key)
+		return err == nil && keyAlg == alg
+	}
+	return false
+}
+
+// algHash returns the hash function used by a JWS algorithm.
+func algHash(alg string) (crypto.Hash, error) {
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		return crypto.SHA256, nil
+	case "RS384", "PS384", "ES384":
+		return crypto.SHA384, nil
+	case "RS512", "PS512", "ES512":
+		return crypto.SHA512, nil
+	}
+	return 0, ErrUnsupportedAlg
+}
+
+// JSONWebKey is a public JSON Web Key (RFC 7517).
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	// RSA public key fields
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC public key fields
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is a JSON Web Key Set.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// NewJSONWebKey returns the JSONWebKey for an RSA or EC public key.
+func NewJSONWebKey(key crypto.PublicKey) (*JSONWebKey, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return &JSONWebKey{
+			KeyType: "RSA",
+			N:       base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		x := make([]byte, size)
+		y := make([]byte, size)
+		k.X.FillBytes(x)
+		k.Y.FillBytes(y)
+		return &JSONWebKey{
+			KeyType: "EC",
+			Curve:   k.Curve.Params().Name,
+			X:       base64.RawURLEncoding.EncodeToString(x),
+			Y:       base64.RawURLEncoding.EncodeToString(y),
+		}, nil
+	}
+	return nil, ErrUnsupportedKeyType
+}
+
+// PublicKey returns the RSA or EC public key of the JSONWebKey.
+func (k JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jose: invalid RSA modulus: %v", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("jose: invalid RSA exponent: %v", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrUnsupportedKeyType
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("jose: invalid EC x coordinate: %v", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("jose: invalid EC y coordinate: %v", err)
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if _, err := pub.ECDH(); err != nil {
+			return nil, fmt.Errorf("jose: invalid EC public key: %v", err)
+		}
+		return pub, nil
+	}
+	return nil, ErrUnsupportedKeyType
+}
@@ -0,0 +1,125 @@
+package jose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testClaims struct {
+	Subject string `json:"sub"`
+}
+
+func TestSignVerify(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ec384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	cases := []struct {
+		key crypto.Signer
+		alg string
+	}{
+		{ecKey, "ES256"},
+		{ec384Key, "ES384"},
+		{rsaKey, "RS256"},
+		{rsaKey, "RS512"},
+		{rsaKey, "PS256"},
+	}
+	for _, c := range cases {
+		raw, err := Sign(c.key, Header{Algorithm: c.alg}, testClaims{Subject: "alice"})
+		assert.Nil(t, err)
+		jwt, err := Parse(raw)
+		assert.Nil(t, err)
+		assert.Equal(t, c.alg, jwt.Header.Algorithm)
+		assert.Nil(t, jwt.Verify(c.key.Public()), c.alg)
+		claims := new(testClaims)
+		assert.Nil(t, jwt.Claims(claims))
+		assert.Equal(t, "alice", claims.Subject)
+	}
+}
+
+func TestSign_DefaultAlgorithm(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	raw, err := Sign(ecKey, Header{}, testClaims{})
+	assert.Nil(t, err)
+	jwt, err := Parse(raw)
+	assert.Nil(t, err)
+	assert.Equal(t, "ES384", jwt.Header.Algorithm)
+}
+
+// Verify must not let the untrusted header choose an algorithm the key was
+// not meant for, assert that:
+// - ES algorithms are bound to the curve of the EC key
+// - RSA algorithms are rejected for EC keys and vice versa
+// - the "none" and HMAC algorithms are rejected
+func TestVerify_AlgorithmErrors(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ec384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	// ES256 header, but signed by a P-384 key
+	wrongCurve, _ := Sign(ec384Key, Header{Algorithm: "ES256"}, testClaims{})
+	// ES384 header, but signed by a P-256 key
+	wrongHash, _ := Sign(ecKey, Header{Algorithm: "ES384"}, testClaims{})
+	rsaSigned, _ := Sign(rsaKey, Header{Algorithm: "RS256"}, testClaims{})
+	ecSigned, _ := Sign(ecKey, Header{Algorithm: "ES256"}, testClaims{})
+	none := unsignedJWT(`{"alg":"none"}`)
+	hmac := unsignedJWT(`{"alg":"HS256"}`)
+
+	cases := []struct {
+		name     string
+		rawToken string
+		key      crypto.PublicKey
+		expected error
+	}{
+		{"ES256 with P-384 key", wrongCurve, ec384Key.Public(), ErrAlgMismatch},
+		{"ES384 with P-256 key", wrongHash, ecKey.Public(), ErrAlgMismatch},
+		{"RS256 with EC key", rsaSigned, ecKey.Public(), ErrAlgMismatch},
+		{"ES256 with RSA key", ecSigned, rsaKey.Public(), ErrAlgMismatch},
+		{"none", none, ecKey.Public(), ErrUnsupportedAlg},
+		{"HS256", hmac, rsaKey.Public(), ErrUnsupportedAlg},
+	}
+	for _, c := range cases {
+		jwt, err := Parse(c.rawToken)
+		assert.Nil(t, err, c.name)
+		assert.Equal(t, c.expected, jwt.Verify(c.key), c.name)
+	}
+}
+
+func TestVerify_InvalidSignature(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	raw, _ := Sign(ecKey, Header{}, testClaims{Subject: "alice"})
+
+	// signed by a different key
+	jwt, err := Parse(raw)
+	assert.Nil(t, err)
+	assert.Equal(t, ErrInvalidSignature, jwt.Verify(otherKey.Public()))
+
+	// payload modified after signing
+	parts := strings.Split(raw, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"mallory"}`))
+	jwt, err = Parse(strings.Join(parts, "."))
+	assert.Nil(t, err)
+	assert.Equal(t, ErrInvalidSignature, jwt.Verify(ecKey.Public()))
+}
+
+func TestParse_Malformed(t *testing.T) {
+	for _, raw := range []string{"", "a.b", "a.b.c.d", "!!.e30.e30"} {
+		_, err := Parse(raw)
+		assert.Equal(t, ErrMalformed, err, raw)
+	}
+}
+
+// unsignedJWT returns a JWT with the header and an arbitrary signature.
+func unsignedJWT(header string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(header)) + "." + enc.EncodeToString([]byte(`{}`)) + "." + enc.EncodeToString([]byte("sig"))
+}
@@ -1,6 +1,7 @@
 package oauth1
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/dghubble/gologin/v2"
@@ -8,6 +9,11 @@ import (
 	"github.com/dghubble/oauth1"
 )
 
+// Errors which may occur on callback.
+var (
+	ErrMissingRequestToken = errors.New("oauth1: Request missing oauth_token")
+)
+
 // LoginHandler handles OAuth1 login requests by obtaining a request token and
 // secret (temporary credentials) and adding it to the ctx. If successful,
 // handling delegates to the success handler, otherwise to the failure handler.
@@ -69,6 +75,7 @@ func AuthRedirectHandler(config *oauth1.Config, failure http.Handler) http.Handl
 // kept between the login phase and callback phase. To implement those
 // providers, use the EmptyTempHandler instead.
 func CookieTempHandler(config gologin.CookieConfig, success, failure http.Handler) http.Handler {
+	internal.MustValidConfig(config)
 	if failure == nil {
 		failure = gologin.DefaultFailureHandler
 	}
@@ -82,13 +89,59 @@ func CookieTempHandler(config gologin.CookieConfig, success, failure http.Handle
 			return
 		}
 		// read request secret from the short-lived cookie to add to ctx
-		cookie, err := req.Cookie(config.Name)
+		requestSecret, err = internal.ReadCookie(req, config)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
-		ctx = WithRequestToken(ctx, "", cookie.Value)
+		ctx = WithRequestToken(ctx, "", requestSecret)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// StoreTempHandler persists or retrieves the request token secret (temporary
+// credentials) using a StateStore. If the request token can be read from the
+// ctx (login phase), the secret is saved to the store keyed by the request
+// token. Otherwise (callback phase) the secret for the callback "oauth_token"
+// is loaded, deleted so it may only be used once, and added to the ctx.
+// If the secret cannot be saved, loaded, or deleted (e.g. because a
+// concurrent callback deleted it first), the failure handler is called.
+func StoreTempHandler(store gologin.StateStore, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		requestToken, requestSecret, err := RequestTokenFromContext(ctx)
+		if err == nil {
+			// save request secret to the store
+			if err := store.Save(w, req, requestToken, requestSecret); err != nil {
+				ctx = gologin.WithError(ctx, err)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			success.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// load request secret from the store to add to ctx
+		requestToken = req.FormValue("oauth_token")
+		if requestToken == "" {
+			ctx = gologin.WithError(ctx, ErrMissingRequestToken)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		requestSecret, err = store.Load(req, requestToken)
+		if err == nil {
+			err = store.Delete(w, req, requestToken)
+		}
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithRequestToken(ctx, requestToken, requestSecret)
 		success.ServeHTTP(w, req.WithContext(ctx))
 	}
 	return http.HandlerFunc(fn)
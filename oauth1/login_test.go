@@ -7,8 +7,10 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/dghubble/gologin/v2"
+	"github.com/dghubble/gologin/v2/store"
 	"github.com/dghubble/gologin/v2/testutils"
 	"github.com/dghubble/oauth1"
 	"github.com/stretchr/testify/assert"
@@ -159,6 +161,63 @@ func TestAuthRedirectHandler_AuthorizationURL(t *testing.T) {
 	assert.Equal(t, "failure handler called", w.Body.String())
 }
 
+// StoreTempHandler
+
+func TestStoreTempHandler(t *testing.T) {
+	tempStore := store.NewMemoryStore(time.Minute)
+	loginSuccess := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	callbackSuccess := func(w http.ResponseWriter, req *http.Request) {
+		requestToken, requestSecret, err := RequestTokenFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "request_token", requestToken)
+		assert.Equal(t, "request_secret", requestSecret)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// StoreTempHandler login phase, assert that:
+	// - request secret is saved to the store
+	handler := StoreTempHandler(tempStore, http.HandlerFunc(loginSuccess), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/login", nil)
+	ctx := WithRequestToken(context.Background(), "request_token", "request_secret")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+
+	// StoreTempHandler callback phase, assert that:
+	// - request secret for the callback oauth_token is added to the ctx
+	handler = StoreTempHandler(tempStore, http.HandlerFunc(callbackSuccess), failure)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/callback?oauth_token=request_token&oauth_verifier=verifier", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+
+	// request secret was deleted and cannot be loaded again
+	_, err := tempStore.Load(req, "request_token")
+	assert.Equal(t, gologin.ErrStateNotFound, err)
+}
+
+func TestStoreTempHandler_MissingRequestToken(t *testing.T) {
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.Equal(t, ErrMissingRequestToken, err)
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// StoreTempHandler called without request token in ctx or oauth_token
+	// param, assert that:
+	// - failure handler is called
+	// - error about the missing oauth_token is added to the ctx
+	handler := StoreTempHandler(store.NewMemoryStore(time.Minute), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/callback", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
 // CallbackHandler
 
 func TestCallbackHandler(t *testing.T) {
@@ -0,0 +1,234 @@
+package oauth2
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin/v2/internal/jose"
+	"golang.org/x/oauth2"
+)
+
+// jwtBearerAssertionType is the RFC 7523 client assertion type.
+const jwtBearerAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// assertionLifetime is how long private_key_jwt client assertions are valid.
+const assertionLifetime = 5 * time.Minute
+
+// ClientAuth authenticates an OAuth2 client to a token endpoint, in place of
+// a client secret.
+type ClientAuth interface {
+	// Transport returns a RoundTripper which authenticates requests to the
+	// tokenURL and sends other requests with base unchanged.
+	Transport(tokenURL string, base http.RoundTripper) http.RoundTripper
+}
+
+// PrivateKeyJWT returns a ClientAuth which authenticates with a JWT client
+// assertion signed by the key (private_key_jwt, RFC 7523). The keyID should
+// identify the key's public JSON Web Key registered with the provider.
+func PrivateKeyJWT(clientID string, key crypto.Signer, keyID string) ClientAuth {
+	return &privateKeyJWT{clientID: clientID, key: key, keyID: keyID}
+}
+
+type privateKeyJWT struct {
+	clientID string
+	key      crypto.Signer
+	keyID    string
+}
+
+func (a *privateKeyJWT) Transport(tokenURL string, base http.RoundTripper) http.RoundTripper {
+	authenticate := func(form url.Values) error {
+		now := time.Now()
+		claims := map[string]interface{}{
+			"iss": a.clientID,
+			"sub": a.clientID,
+			"aud": tokenURL,
+			"jti": randomState(),
+			"iat": now.Unix(),
+			"exp": now.Add(assertionLifetime).Unix(),
+		}
+		assertion, err := jose.Sign(a.key, jose.Header{KeyID: a.keyID, Type: "JWT"}, claims)
+		if err != nil {
+			return err
+		}
+		form.Set("client_id", a.clientID)
+		form.Set("client_assertion_type", jwtBearerAssertionType)
+		form.Set("client_assertion", assertion)
+		return nil
+	}
+	return newTokenRequestTransport(tokenURL, base, authenticate)
+}
+
+// ErrClientCertTransport is returned by token requests authenticated with
+// MutualTLS when the base RoundTripper cannot offer a client certificate.
+var ErrClientCertTransport = errors.New("oauth2: MutualTLS requires an *http.Transport base RoundTripper")
+
+// MutualTLS returns a ClientAuth which authenticates with a TLS client
+// certificate (RFC 8705). Requests to the token endpoint send the client_id
+// and offer the certificate, while other requests are sent with the base
+// RoundTripper unchanged.
+//
+// To offer the certificate, the base RoundTripper must be an *http.Transport
+// (whose TLS configuration is cloned), or a RoundTripper of this package
+// (e.g. from NewDPoPTransport) wrapping one. Otherwise, token requests fail
+// with ErrClientCertTransport.
+func MutualTLS(clientID string, cert tls.Certificate) ClientAuth {
+	return &mutualTLS{clientID: clientID, cert: cert}
+}
+
+type mutualTLS struct {
+	clientID string
+	cert     tls.Certificate
+}
+
+func (a *mutualTLS) Transport(tokenURL string, base http.RoundTripper) http.RoundTripper {
+	authenticate := func(form url.Values) error {
+		form.Set("client_id", a.clientID)
+		return nil
+	}
+	transport := newTokenRequestTransport(tokenURL, base, authenticate)
+	transport.tokenBase = withClientCert(base, a.cert)
+	return transport
+}
+
+// withClientCert returns a copy of the RoundTripper which offers the client
+// certificate, or a RoundTripper which fails with ErrClientCertTransport.
+func withClientCert(base http.RoundTripper, cert tls.Certificate) http.RoundTripper {
+	switch t := base.(type) {
+	case *http.Transport:
+		t = t.Clone()
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		t.TLSClientConfig.Certificates = []tls.Certificate{cert}
+		return t
+	case *dpopTransport:
+		return &dpopTransport{
+			key:      t.key,
+			tokenURL: t.tokenURL,
+			base:     withClientCert(t.base, cert),
+			nonces:   make(map[string]string),
+		}
+	case *tokenRequestTransport:
+		return &tokenRequestTransport{
+			tokenURL:     t.tokenURL,
+			base:         withClientCert(t.base, cert),
+			tokenBase:    withClientCert(t.tokenBase, cert),
+			authenticate: t.authenticate,
+		}
+	}
+	return errTransport{ErrClientCertTransport}
+}
+
+// errTransport is a RoundTripper which fails every request with err.
+type errTransport struct {
+	err error
+}
+
+func (t errTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, t.err
+}
+
+// tokenRequestTransport rewrites the form of requests to a token endpoint
+// to authenticate the client and sends them with tokenBase. Client secrets
+// are removed. Other requests are sent with base.
+type tokenRequestTransport struct {
+	tokenURL     *url.URL
+	base         http.RoundTripper
+	tokenBase    http.RoundTripper
+	authenticate func(form url.Values) error
+}
+
+func newTokenRequestTransport(tokenURL string, base http.RoundTripper, authenticate func(form url.Values) error) *tokenRequestTransport {
+	return &tokenRequestTransport{
+		tokenURL:     parseEndpoint(tokenURL),
+		base:         base,
+		tokenBase:    base,
+		authenticate: authenticate,
+	}
+}
+
+func (t *tokenRequestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "POST" || !sameEndpoint(req.URL, t.tokenURL) {
+		return t.base.RoundTrip(req)
+	}
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	form.Del("client_secret")
+	if err := t.authenticate(form); err != nil {
+		return nil, err
+	}
+	encoded := form.Encode()
+	req = req.Clone(req.Context())
+	req.Header.Del("Authorization")
+	req.Body = io.NopCloser(strings.NewReader(encoded))
+	req.ContentLength = int64(len(encoded))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader([]byte(encoded))), nil
+	}
+	return t.tokenBase.RoundTrip(req)
+}
+
+// parseEndpoint parses an endpoint URL. Invalid URLs parse as an empty URL,
+// which no request matches.
+func parseEndpoint(rawURL string) *url.URL {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return &url.URL{}
+	}
+	return parsed
+}
+
+// sameEndpoint returns true if the URL is the endpoint, ignoring queries.
+func sameEndpoint(u, endpoint *url.URL) bool {
+	return u.Scheme == endpoint.Scheme && u.Host == endpoint.Host && u.Path == endpoint.Path
+}
+
+// WithClientAuth returns a copy of ctx whose oauth2.HTTPClient authenticates
+// token requests to the Config TokenURL with the ClientAuth, so token
+// exchanges and refreshes with the ctx (e.g. RefreshHandler or DeviceLogin)
+// use it. Pushed authorization requests (see PushedAuthHandler) are
+// authenticated too. The Config ClientSecret should be empty.
+func WithClientAuth(ctx context.Context, config *oauth2.Config, auth ClientAuth) context.Context {
+	base := contextClient(ctx)
+	transport := base.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client := *base
+	client.Transport = auth.Transport(config.Endpoint.TokenURL, transport)
+	ctx = context.WithValue(ctx, clientAuthKey, clientAuthBase{auth: auth, client: base})
+	return context.WithValue(ctx, oauth2.HTTPClient, &client)
+}
+
+// ClientAuthHandler authenticates the client with the ClientAuth in token
+// requests made by downstream handlers (e.g. CallbackHandler), in place of a
+// client secret. See WithClientAuth.
+func ClientAuthHandler(config *oauth2.Config, auth ClientAuth, success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := WithClientAuth(req.Context(), config, auth)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
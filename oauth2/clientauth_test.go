@@ -0,0 +1,219 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin/v2/internal/jose"
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestClientAuthHandler_PrivateKeyJWT(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	var tokenURL string
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _, hasBasicAuth := req.BasicAuth()
+		assert.False(t, hasBasicAuth)
+		assert.Equal(t, "", req.PostFormValue("client_secret"))
+		assert.Equal(t, "client_id", req.PostFormValue("client_id"))
+		assert.Equal(t, "urn:ietf:params:oauth:client-assertion-type:jwt-bearer", req.PostFormValue("client_assertion_type"))
+		jwt, err := jose.Parse(req.PostFormValue("client_assertion"))
+		if assert.Nil(t, err) {
+			assert.Nil(t, jwt.Verify(&key.PublicKey))
+			assert.Equal(t, "key-1", jwt.Header.KeyID)
+			var claims map[string]interface{}
+			assert.Nil(t, json.Unmarshal(jwt.Payload, &claims))
+			assert.Equal(t, "client_id", claims["iss"])
+			assert.Equal(t, "client_id", claims["sub"])
+			assert.Equal(t, tokenURL, claims["aud"])
+			assert.NotEmpty(t, claims["jti"])
+		}
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`))
+	})
+	defer server.Close()
+	tokenURL = server.URL + "/token"
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			TokenURL: tokenURL,
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		_, err := TokenFromContext(req.Context())
+		assert.Nil(t, err)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// ClientAuthHandler with PrivateKeyJWT before CallbackHandler, assert that:
+	// - the token exchange sends a signed client assertion
+	// - success handler is called
+	auth := PrivateKeyJWT("client_id", key, "key-1")
+	handler := ClientAuthHandler(config, auth, CallbackHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t)))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	handler.ServeHTTP(w, req.WithContext(WithState(context.Background(), "d4e5f6")))
+	assert.Equal(t, "success handler called", w.Body.String())
+
+	// refreshes with the ctx are authenticated too
+	ctx := WithClientAuth(context.Background(), config, auth)
+	_, err = config.TokenSource(ctx, &oauth2.Token{RefreshToken: "refresh_token"}).Token()
+	assert.Nil(t, err)
+}
+
+func TestClientAuth_OtherRequests(t *testing.T) {
+	api := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		assert.Equal(t, "", req.PostFormValue("client_assertion"))
+	})
+	defer api.Close()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: "https://provider.example.com/token",
+		},
+	}
+
+	// requests to other URLs are not modified
+	ctx := WithClientAuth(context.Background(), config, PrivateKeyJWT("client_id", key, "key-1"))
+	client := ctx.Value(oauth2.HTTPClient).(*http.Client)
+	resp, err := client.PostForm(api.URL+"/token", nil)
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+	}
+}
+
+// newClientCertificate returns a self-signed TLS client certificate.
+func newClientCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client_id"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestClientAuthHandler_MutualTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if assert.Len(t, req.TLS.PeerCertificates, 1) {
+			assert.Equal(t, "client_id", req.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+		assert.Equal(t, "client_id", req.PostFormValue("client_id"))
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL + "/token",
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// ClientAuthHandler with MutualTLS before CallbackHandler, assert that:
+	// - the token exchange presents the client certificate
+	// - success handler is called
+	handler := ClientAuthHandler(config, MutualTLS("client_id", newClientCertificate(t)), CallbackHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t)))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	// trust the test server's certificate
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, server.Client())
+	handler.ServeHTTP(w, req.WithContext(WithState(ctx, "d4e5f6")))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestClientAuthHandler_MutualTLSWithDPoP(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Len(t, req.TLS.PeerCertificates, 1)
+		claims := verifyDPoPProof(t, req)
+		assert.Nil(t, claims["ath"])
+		assert.Equal(t, "client_id", req.PostFormValue("client_id"))
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"DPoP"}`))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+	api := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Len(t, req.TLS.PeerCertificates, 0)
+		assert.Equal(t, "", req.Header.Get("DPoP"))
+	}))
+	api.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	api.StartTLS()
+	defer api.Close()
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			TokenURL:  server.URL + "/token",
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		token, err := TokenFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "DPoP", token.Type())
+		client := req.Context().Value(oauth2.HTTPClient).(*http.Client)
+		resp, err := client.Get(api.URL + "/jwks")
+		if assert.Nil(t, err) {
+			resp.Body.Close()
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+	key, err := GenerateDPoPKey()
+	assert.Nil(t, err)
+
+	// WithDPoP and ClientAuthHandler with MutualTLS, assert that:
+	// - the token exchange presents the client certificate and a DPoP proof
+	// - other requests don't present the client certificate
+	// - success handler is called
+	handler := ClientAuthHandler(config, MutualTLS("client_id", newClientCertificate(t)), CallbackHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t)))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	// trust the test server's certificate
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, server.Client())
+	ctx = WithDPoP(ctx, config.Endpoint.TokenURL, key)
+	handler.ServeHTTP(w, req.WithContext(WithState(ctx, "d4e5f6")))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestMutualTLS_UnsupportedTransport(t *testing.T) {
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			TokenURL: "https://provider.example.com/token",
+		},
+	}
+	client := &http.Client{Transport: &testutils.RewriteTransport{}}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+
+	// token requests fail if the base can't offer the client certificate
+	ctx = WithClientAuth(ctx, config, MutualTLS("client_id", newClientCertificate(t)))
+	_, err := config.Exchange(ctx, "any_code")
+	assert.ErrorIs(t, err, ErrClientCertTransport)
+}
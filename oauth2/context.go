@@ -3,7 +3,9 @@ package oauth2
 import (
 	"context"
 	"fmt"
+	"net/http"
 
+	"github.com/dghubble/gologin/v2"
 	"golang.org/x/oauth2"
 )
 
@@ -13,6 +15,20 @@ type key int
 const (
 	tokenKey key = iota
 	stateKey
+	verifierKey
+	nonceKey
+	returnToKey
+	authCodeOptionsKey
+	cookiesKey
+	statePayloadKey
+	stateVerifierKey
+	flowKey
+	clientKey
+	issuerKey
+	clientAuthKey
+	pushedAuthURLKey
+	dpopKeyKey
+	stateProviderKey
 )
 
 // WithState returns a copy of ctx that stores the state value.
@@ -29,6 +45,130 @@ func StateFromContext(ctx context.Context) (string, error) {
 	return state, nil
 }
 
+// WithVerifier returns a copy of ctx that stores the PKCE code verifier.
+func WithVerifier(ctx context.Context, verifier string) context.Context {
+	return context.WithValue(ctx, verifierKey, verifier)
+}
+
+// VerifierFromContext returns the PKCE code verifier from the ctx.
+func VerifierFromContext(ctx context.Context) (string, error) {
+	verifier, ok := ctx.Value(verifierKey).(string)
+	if !ok {
+		return "", fmt.Errorf("oauth2: Context missing PKCE verifier")
+	}
+	return verifier, nil
+}
+
+// WithNonce returns a copy of ctx that stores the nonce value.
+func WithNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, nonceKey, nonce)
+}
+
+// NonceFromContext returns the nonce value from the ctx. Callback handlers
+// which verify ID tokens compare the ID token nonce claim to this value.
+func NonceFromContext(ctx context.Context) (string, error) {
+	nonce, ok := ctx.Value(nonceKey).(string)
+	if !ok {
+		return "", fmt.Errorf("oauth2: Context missing nonce value")
+	}
+	return nonce, nil
+}
+
+// WithReturnTo returns a copy of ctx that stores the URL to return to after
+// login.
+func WithReturnTo(ctx context.Context, returnTo string) context.Context {
+	return context.WithValue(ctx, returnToKey, returnTo)
+}
+
+// ReturnToFromContext returns the URL to return to after login from the ctx.
+func ReturnToFromContext(ctx context.Context) (string, error) {
+	returnTo, ok := ctx.Value(returnToKey).(string)
+	if !ok {
+		return "", fmt.Errorf("oauth2: Context missing return to URL")
+	}
+	return returnTo, nil
+}
+
+// WithAuthCodeOptions returns a copy of ctx that adds the AuthCodeOptions
+// LoginHandler should pass to AuthCodeURL (e.g. "prompt", "login_hint",
+// "access_type", or "scope" parameters). Upstream handlers may add options
+// based on the incoming request.
+func WithAuthCodeOptions(ctx context.Context, opts ...oauth2.AuthCodeOption) context.Context {
+	existing := AuthCodeOptionsFromContext(ctx)
+	opts = append(existing[:len(existing):len(existing)], opts...)
+	return context.WithValue(ctx, authCodeOptionsKey, opts)
+}
+
+// AuthCodeOptionsFromContext returns the AuthCodeOptions added to the ctx,
+// if any.
+func AuthCodeOptionsFromContext(ctx context.Context) []oauth2.AuthCodeOption {
+	opts, _ := ctx.Value(authCodeOptionsKey).([]oauth2.AuthCodeOption)
+	return opts
+}
+
+// WithClient returns a copy of ctx that stores an http.Client which
+// authorizes requests with the user's Token.
+func WithClient(ctx context.Context, client *http.Client) context.Context {
+	return context.WithValue(ctx, clientKey, client)
+}
+
+// ClientFromContext returns the authorized http.Client from the ctx.
+func ClientFromContext(ctx context.Context) (*http.Client, error) {
+	client, ok := ctx.Value(clientKey).(*http.Client)
+	if !ok {
+		return nil, fmt.Errorf("oauth2: Context missing http Client")
+	}
+	return client, nil
+}
+
+// WithStatePayload returns a copy of ctx that stores the payload of a
+// verified signed state.
+func WithStatePayload(ctx context.Context, payload map[string]string) context.Context {
+	return context.WithValue(ctx, statePayloadKey, payload)
+}
+
+// StatePayloadFromContext returns the payload of a verified signed state
+// from the ctx.
+func StatePayloadFromContext(ctx context.Context) (map[string]string, error) {
+	payload, ok := ctx.Value(statePayloadKey).(map[string]string)
+	if !ok {
+		return nil, fmt.Errorf("oauth2: Context missing state payload")
+	}
+	return payload, nil
+}
+
+// WithIssuer returns a copy of ctx that stores the expected authorization
+// server issuer identifier.
+func WithIssuer(ctx context.Context, issuer string) context.Context {
+	return context.WithValue(ctx, issuerKey, issuer)
+}
+
+// IssuerFromContext returns the expected authorization server issuer
+// identifier from the ctx.
+func IssuerFromContext(ctx context.Context) (string, error) {
+	issuer, ok := ctx.Value(issuerKey).(string)
+	if !ok {
+		return "", fmt.Errorf("oauth2: Context missing issuer")
+	}
+	return issuer, nil
+}
+
+// WithPushedAuthURL returns a copy of ctx that stores the Pushed
+// Authorization Request endpoint URL LoginHandler should push to.
+func WithPushedAuthURL(ctx context.Context, pushedAuthURL string) context.Context {
+	return context.WithValue(ctx, pushedAuthURLKey, pushedAuthURL)
+}
+
+// PushedAuthURLFromContext returns the Pushed Authorization Request endpoint
+// URL from the ctx.
+func PushedAuthURLFromContext(ctx context.Context) (string, error) {
+	pushedAuthURL, ok := ctx.Value(pushedAuthURLKey).(string)
+	if !ok {
+		return "", fmt.Errorf("oauth2: Context missing pushed authorization URL")
+	}
+	return pushedAuthURL, nil
+}
+
 // WithToken returns a copy of ctx that stores the Token.
 func WithToken(ctx context.Context, token *oauth2.Token) context.Context {
 	return context.WithValue(ctx, tokenKey, token)
@@ -42,3 +182,56 @@ func TokenFromContext(ctx context.Context) (*oauth2.Token, error) {
 	}
 	return token, nil
 }
+
+// withTempCookie returns a copy of ctx that records a temporary cookie issued
+// for the flow, to be expired by CallbackHandler.
+func withTempCookie(ctx context.Context, config gologin.CookieConfig) context.Context {
+	configs := tempCookiesFromContext(ctx)
+	configs = append(configs[:len(configs):len(configs)], config)
+	return context.WithValue(ctx, cookiesKey, configs)
+}
+
+// tempCookiesFromContext returns the temporary cookies recorded in the ctx.
+func tempCookiesFromContext(ctx context.Context) []gologin.CookieConfig {
+	configs, _ := ctx.Value(cookiesKey).([]gologin.CookieConfig)
+	return configs
+}
+
+// stateVerifier verifies a callback state value, returning its payload.
+type stateVerifier func(req *http.Request, state string) (map[string]string, error)
+
+// withStateVerifier returns a copy of ctx that stores a stateVerifier which
+// CallbackHandler uses in place of comparing the state with the ctx state.
+func withStateVerifier(ctx context.Context, verify stateVerifier) context.Context {
+	return context.WithValue(ctx, stateVerifierKey, verify)
+}
+
+// stateVerifierFromContext returns the stateVerifier from the ctx, if any.
+func stateVerifierFromContext(ctx context.Context) (stateVerifier, bool) {
+	verify, ok := ctx.Value(stateVerifierKey).(stateVerifier)
+	return verify, ok
+}
+
+// withFlowID returns a copy of ctx that stores the ID of the login flow.
+func withFlowID(ctx context.Context, flowID string) context.Context {
+	return context.WithValue(ctx, flowKey, flowID)
+}
+
+// flowIDFromContext returns the ID of the login flow from the ctx, if any.
+func flowIDFromContext(ctx context.Context) (string, bool) {
+	flowID, ok := ctx.Value(flowKey).(string)
+	return flowID, ok
+}
+
+// withStateProvider returns a copy of ctx that stores the name of the
+// provider the state was issued for.
+func withStateProvider(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, stateProviderKey, name)
+}
+
+// stateProviderFromContext returns the name of the provider the state was
+// issued for, if recorded.
+func stateProviderFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(stateProviderKey).(string)
+	return name, ok
+}
@@ -39,3 +39,60 @@ func TestTokenFromContext_Error(t *testing.T) {
 		assert.Equal(t, "oauth2: Context missing Token", err.Error())
 	}
 }
+
+func TestContext_Verifier(t *testing.T) {
+	expectedVerifier := "verifier"
+	ctx := WithVerifier(context.Background(), expectedVerifier)
+	verifier, err := VerifierFromContext(ctx)
+	assert.Equal(t, expectedVerifier, verifier)
+	assert.Nil(t, err)
+}
+
+func TestContext_MissingVerifier(t *testing.T) {
+	verifier, err := VerifierFromContext(context.Background())
+	assert.Equal(t, "", verifier)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "oauth2: Context missing PKCE verifier", err.Error())
+	}
+}
+
+func TestContext_Nonce(t *testing.T) {
+	expectedNonce := "nonce"
+	ctx := WithNonce(context.Background(), expectedNonce)
+	nonce, err := NonceFromContext(ctx)
+	assert.Equal(t, expectedNonce, nonce)
+	assert.Nil(t, err)
+}
+
+func TestContext_MissingNonce(t *testing.T) {
+	nonce, err := NonceFromContext(context.Background())
+	assert.Equal(t, "", nonce)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "oauth2: Context missing nonce value", err.Error())
+	}
+}
+
+func TestContext_ReturnTo(t *testing.T) {
+	expectedReturnTo := "/settings"
+	ctx := WithReturnTo(context.Background(), expectedReturnTo)
+	returnTo, err := ReturnToFromContext(ctx)
+	assert.Equal(t, expectedReturnTo, returnTo)
+	assert.Nil(t, err)
+}
+
+func TestContext_MissingReturnTo(t *testing.T) {
+	returnTo, err := ReturnToFromContext(context.Background())
+	assert.Equal(t, "", returnTo)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "oauth2: Context missing return to URL", err.Error())
+	}
+}
+
+func TestContext_AuthCodeOptions(t *testing.T) {
+	assert.Empty(t, AuthCodeOptionsFromContext(context.Background()))
+	prompt := oauth2.SetAuthURLParam("prompt", "consent")
+	ctx := WithAuthCodeOptions(context.Background(), oauth2.AccessTypeOffline)
+	ctx = WithAuthCodeOptions(ctx, prompt)
+	opts := AuthCodeOptionsFromContext(ctx)
+	assert.Equal(t, []oauth2.AuthCodeOption{oauth2.AccessTypeOffline, prompt}, opts)
+}
@@ -0,0 +1,69 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/oauth2"
+)
+
+// Device flow errors
+var (
+	ErrDeviceUnsupported = errors.New("oauth2: Config Endpoint missing DeviceAuthURL")
+	ErrDeviceCodeExpired = errors.New("oauth2: Device code expired before the user granted access")
+)
+
+// DevicePrompt shows the user where to go and which code to enter to grant
+// a device access (e.g. prints the VerificationURI and UserCode).
+type DevicePrompt func(auth *oauth2.DeviceAuthResponse) error
+
+// WriteDevicePrompt returns a DevicePrompt which writes login instructions to w.
+func WriteDevicePrompt(w io.Writer) DevicePrompt {
+	return func(auth *oauth2.DeviceAuthResponse) error {
+		_, err := fmt.Fprintf(w, "To sign in, open %s and enter the code %s\n", auth.VerificationURI, auth.UserCode)
+		return err
+	}
+}
+
+// DeviceLogin obtains an OAuth2 Token with the Device Authorization Grant
+// (RFC 8628), for CLIs or devices without a browser. It requests a device
+// and user code from the Config Endpoint DeviceAuthURL, calls prompt to show
+// them to the user, and polls the TokenURL until the user grants access,
+// respecting the interval and slow_down responses.
+//
+// If the user denies access, an *AuthorizationError is returned. If the
+// device code expires first, ErrDeviceCodeExpired is returned. Polling stops
+// if the ctx is cancelled.
+func DeviceLogin(ctx context.Context, config *oauth2.Config, prompt DevicePrompt, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	if config.Endpoint.DeviceAuthURL == "" {
+		return nil, ErrDeviceUnsupported
+	}
+	auth, err := config.DeviceAuth(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := prompt(auth); err != nil {
+		return nil, err
+	}
+	token, err := config.DeviceAccessToken(ctx, auth)
+	if err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		switch {
+		case errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "expired_token":
+			return nil, ErrDeviceCodeExpired
+		case errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "access_denied":
+			return nil, &AuthorizationError{
+				Code:        retrieveErr.ErrorCode,
+				Description: retrieveErr.ErrorDescription,
+				URI:         retrieveErr.ErrorURI,
+			}
+		case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+			// polling stopped at the device code expiry
+			return nil, ErrDeviceCodeExpired
+		}
+		return nil, err
+	}
+	return token, nil
+}
@@ -0,0 +1,81 @@
+package oauth2
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// newDeviceServer returns a server whose device authorization endpoint
+// issues a device code and whose token endpoint responds with the given
+// responses in order. The caller must close the server.
+func newDeviceServer(t *testing.T, responses ...string) (*oauth2.Config, func()) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/device", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "client_id", req.PostFormValue("client_id"))
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"device_code":"device_code_val","user_code":"WDJB-MJHT","verification_uri":"https://example.com/device","expires_in":60,"interval":1}`))
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:device_code", req.PostFormValue("grant_type"))
+		assert.Equal(t, "device_code_val", req.PostFormValue("device_code"))
+		response := responses[0]
+		responses = responses[1:]
+		w.Header().Set(contentType, jsonContentType)
+		if bytes.Contains([]byte(response), []byte(`"error"`)) {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		w.Write([]byte(response))
+	})
+	server := NewTestServerFunc(mux.ServeHTTP)
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			DeviceAuthURL: server.URL + "/device",
+			TokenURL:      server.URL + "/token",
+			AuthStyle:     oauth2.AuthStyleInParams,
+		},
+	}
+	return config, server.Close
+}
+
+func TestDeviceLogin(t *testing.T) {
+	config, closeServer := newDeviceServer(t,
+		`{"error":"authorization_pending"}`,
+		`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`,
+	)
+	defer closeServer()
+
+	// DeviceLogin, assert that:
+	// - the user is prompted with the verification URI and user code
+	// - the token endpoint is polled until access is granted
+	var out bytes.Buffer
+	token, err := DeviceLogin(context.Background(), config, WriteDevicePrompt(&out))
+	assert.Nil(t, err)
+	assert.Equal(t, "2YotnFZFEjr1zCsicMWpAA", token.AccessToken)
+	assert.Equal(t, "To sign in, open https://example.com/device and enter the code WDJB-MJHT\n", out.String())
+}
+
+func TestDeviceLogin_Errors(t *testing.T) {
+	cases := []struct {
+		response string
+		err      error
+	}{
+		{`{"error":"access_denied","error_description":"denied"}`, &AuthorizationError{Code: "access_denied", Description: "denied"}},
+		{`{"error":"expired_token"}`, ErrDeviceCodeExpired},
+	}
+	for _, c := range cases {
+		config, closeServer := newDeviceServer(t, c.response)
+		_, err := DeviceLogin(context.Background(), config, func(*oauth2.DeviceAuthResponse) error { return nil })
+		assert.Equal(t, c.err, err)
+		closeServer()
+	}
+
+	// Config without a DeviceAuthURL
+	_, err := DeviceLogin(context.Background(), &oauth2.Config{}, WriteDevicePrompt(&bytes.Buffer{}))
+	assert.Equal(t, ErrDeviceUnsupported, err)
+}
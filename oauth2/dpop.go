@@ -0,0 +1,227 @@
+package oauth2
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/dghubble/gologin/v2/internal/jose"
+	"golang.org/x/oauth2"
+)
+
+// dpopProofType is the RFC 9449 DPoP proof JWT type.
+const dpopProofType = "dpop+jwt"
+
+// DPoPKey is a DPoP (RFC 9449) proof key, which signs proofs of possession
+// for requests so DPoP-bound tokens are useless to anyone without the key.
+type DPoPKey struct {
+	signer crypto.Signer
+	jwk    *jose.JSONWebKey
+}
+
+// NewDPoPKey returns a DPoPKey which signs proofs with an RSA or EC signer.
+func NewDPoPKey(signer crypto.Signer) (*DPoPKey, error) {
+	jwk, err := jose.NewJSONWebKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &DPoPKey{signer: signer, jwk: jwk}, nil
+}
+
+// GenerateDPoPKey returns a new P-256 DPoPKey.
+func GenerateDPoPKey() (*DPoPKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewDPoPKey(key)
+}
+
+// Signer returns the signer of the DPoPKey, so it may be persisted with the
+// Tokens bound to it.
+func (k *DPoPKey) Signer() crypto.Signer {
+	return k.signer
+}
+
+// proof returns a DPoP proof JWT for a request. If an accessToken is given,
+// its hash is included as the "ath" claim.
+func (k *DPoPKey) proof(method, htu, accessToken, nonce string) (string, error) {
+	claims := map[string]interface{}{
+		"jti": randomState(),
+		"htm": method,
+		"htu": htu,
+		"iat": time.Now().Unix(),
+	}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	return jose.Sign(k.signer, jose.Header{Type: dpopProofType, JWK: k.jwk}, claims)
+}
+
+// NewDPoPTransport returns a RoundTripper which adds a fresh DPoP proof to
+// token requests (POSTs to the tokenURL) and to requests authorized with a
+// "DPoP" token (as issued for DPoP-bound Tokens), which are proven with the
+// token hash. Other requests, including "Bearer" requests, are sent with base
+// unchanged so the key's public JWK is only shown to the token issuer and
+// DPoP resource servers. Nonces sent by servers in DPoP-Nonce headers are
+// included in later proofs, and requests rejected for a missing or stale
+// nonce are retried once with the new nonce.
+func NewDPoPTransport(key *DPoPKey, tokenURL string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &dpopTransport{
+		key:      key,
+		tokenURL: parseEndpoint(tokenURL),
+		base:     base,
+		nonces:   make(map[string]string),
+	}
+}
+
+type dpopTransport struct {
+	key      *DPoPKey
+	tokenURL *url.URL
+	base     http.RoundTripper
+
+	mu sync.Mutex
+	// nonces are the latest DPoP-Nonce of each host
+	nonces map[string]string
+}
+
+func (t *dpopTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	accessToken, ok := t.proofToken(req)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	nonce := t.nonce(req.URL.Host)
+	resp, err := t.send(req, accessToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	challenge := resp.Header.Get("DPoP-Nonce")
+	retry := resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized
+	if !retry || challenge == "" || challenge == nonce || (req.Body != nil && req.GetBody == nil) {
+		return resp, nil
+	}
+	resp.Body.Close()
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	return t.send(req, accessToken, challenge)
+}
+
+// send sends the request with a new DPoP proof and records the DPoP-Nonce
+// of the response (if any) for later proofs.
+func (t *dpopTransport) send(req *http.Request, accessToken, nonce string) (*http.Response, error) {
+	htu := *req.URL
+	htu.RawQuery = ""
+	htu.Fragment = ""
+	proof, err := t.key.proof(req.Method, htu.String(), accessToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("DPoP", proof)
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if next := resp.Header.Get("DPoP-Nonce"); next != "" {
+		t.setNonce(req.URL.Host, next)
+	}
+	return resp, nil
+}
+
+func (t *dpopTransport) nonce(host string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.nonces[host]
+}
+
+func (t *dpopTransport) setNonce(host, nonce string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nonces[host] = nonce
+}
+
+// proofToken returns the access token of a request authorized with the
+// "DPoP" scheme, or an empty token for token requests without authorization.
+// Returns false for other requests, which are not proven.
+func (t *dpopTransport) proofToken(req *http.Request) (string, bool) {
+	authorization := req.Header.Get("Authorization")
+	if authorization == "" {
+		return "", req.Method == "POST" && sameEndpoint(req.URL, t.tokenURL)
+	}
+	scheme, token, found := strings.Cut(authorization, " ")
+	if found && strings.EqualFold(scheme, "DPoP") {
+		return token, true
+	}
+	return "", false
+}
+
+// WithDPoP returns a copy of ctx which stores the DPoPKey and whose
+// oauth2.HTTPClient adds DPoP proofs signed by it to requests to the tokenURL
+// and requests authorized with DPoP-bound Tokens, so token requests (e.g. by
+// CallbackHandler or RefreshHandler) and Clients made with the ctx (e.g. by
+// Config.Client and provider CallbackHandlers) prove possession of it.
+func WithDPoP(ctx context.Context, tokenURL string, key *DPoPKey) context.Context {
+	base := contextClient(ctx)
+	client := *base
+	client.Transport = NewDPoPTransport(key, tokenURL, base.Transport)
+	ctx = context.WithValue(ctx, dpopKeyKey, key)
+	return context.WithValue(ctx, oauth2.HTTPClient, &client)
+}
+
+// DPoPKeyFromContext returns the DPoPKey from the ctx.
+func DPoPKeyFromContext(ctx context.Context) (*DPoPKey, error) {
+	key, ok := ctx.Value(dpopKeyKey).(*DPoPKey)
+	if !ok {
+		return nil, fmt.Errorf("oauth2: Context missing DPoP key")
+	}
+	return key, nil
+}
+
+// DPoPHandler generates a new DPoPKey and adds it to the ctx with WithDPoP,
+// so the Token obtained from the tokenURL by a downstream CallbackHandler is
+// bound to it. The
+// success handler should persist the key from DPoPKeyFromContext with the
+// Token (e.g. in the user's session) and add it back with WithDPoP to use
+// or refresh the Token later. If a key cannot be generated, the failure
+// handler is called.
+func DPoPHandler(tokenURL string, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		key, err := GenerateDPoPKey()
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithDPoP(ctx, tokenURL, key)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,185 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin/v2/internal/jose"
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// verifyDPoPProof verifies the DPoP proof of a request and returns its claims.
+func verifyDPoPProof(t *testing.T, req *http.Request) map[string]interface{} {
+	jwt, err := jose.Parse(req.Header.Get("DPoP"))
+	if !assert.Nil(t, err) {
+		return nil
+	}
+	assert.Equal(t, "dpop+jwt", jwt.Header.Type)
+	if assert.NotNil(t, jwt.Header.JWK) {
+		key, err := jwt.Header.JWK.PublicKey()
+		assert.Nil(t, err)
+		assert.Nil(t, jwt.Verify(key))
+	}
+	var claims map[string]interface{}
+	assert.Nil(t, json.Unmarshal(jwt.Payload, &claims))
+	assert.Equal(t, req.Method, claims["htm"])
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	assert.Equal(t, scheme+"://"+req.Host+req.URL.Path, claims["htu"])
+	assert.NotEmpty(t, claims["jti"])
+	return claims
+}
+
+func TestDPoPHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		claims := verifyDPoPProof(t, req)
+		assert.Nil(t, claims["ath"])
+		if claims["nonce"] != "nonce-1" {
+			// require a server nonce (RFC 9449 8)
+			w.Header().Set("DPoP-Nonce", "nonce-1")
+			w.Header().Set(contentType, jsonContentType)
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"use_dpop_nonce"}`))
+			return
+		}
+		assert.Equal(t, "any_code", req.PostFormValue("code"))
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"DPoP"}`))
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "DPoP 2YotnFZFEjr1zCsicMWpAA", req.Header.Get("Authorization"))
+		claims := verifyDPoPProof(t, req)
+		sum := sha256.Sum256([]byte("2YotnFZFEjr1zCsicMWpAA"))
+		assert.Equal(t, base64.RawURLEncoding.EncodeToString(sum[:]), claims["ath"])
+		assert.Equal(t, "nonce-1", claims["nonce"])
+		fmt.Fprintf(w, `{"id":"1"}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			TokenURL:  server.URL + "/token",
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		_, err := DPoPKeyFromContext(ctx)
+		assert.Nil(t, err)
+		token, err := TokenFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "DPoP", token.Type())
+		resp, err := config.Client(ctx, token).Get(server.URL + "/userinfo?fields=id")
+		if assert.Nil(t, err) {
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// DPoPHandler before CallbackHandler, assert that:
+	// - the token request has a DPoP proof and is retried with the nonce
+	// - API requests with the Token have a DPoP proof with the token hash
+	// - success handler is called
+	handler := DPoPHandler(config.Endpoint.TokenURL, CallbackHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t)), testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	handler.ServeHTTP(w, req.WithContext(WithState(context.Background(), "d4e5f6")))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestDPoPTransport_Bearer(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer access_token", req.Header.Get("Authorization"))
+		assert.Equal(t, "", req.Header.Get("DPoP"))
+	})
+	defer server.Close()
+	key, err := GenerateDPoPKey()
+	assert.Nil(t, err)
+
+	// DPoP transport sends Bearer requests unchanged
+	client := &http.Client{Transport: NewDPoPTransport(key, server.URL+"/token", nil)}
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("Authorization", "Bearer access_token")
+	resp, err := client.Do(req)
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+	}
+}
+
+func TestDPoPTransport_OtherRequests(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "", req.Header.Get("DPoP"))
+	})
+	defer server.Close()
+	key, err := GenerateDPoPKey()
+	assert.Nil(t, err)
+
+	// DPoP transport only proves token requests and DPoP token requests, so
+	// requests to other endpoints (e.g. JWKS or revocation) are unchanged
+	client := &http.Client{Transport: NewDPoPTransport(key, server.URL+"/token", nil)}
+	resp, err := client.Get(server.URL + "/jwks")
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+	}
+	resp, err = client.PostForm(server.URL+"/revoke", nil)
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+	}
+	resp, err = client.Get(server.URL + "/token")
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+	}
+}
+
+func TestDPoPTransport_RotatingNonce(t *testing.T) {
+	var requests int
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		claims := verifyDPoPProof(t, req)
+		// issue a new nonce with every response
+		w.Header().Set("DPoP-Nonce", fmt.Sprintf("nonce-%d", requests))
+		if requests > 1 && claims["nonce"] != fmt.Sprintf("nonce-%d", requests-1) {
+			t.Errorf("expected the latest nonce, got %v", claims["nonce"])
+		}
+		if claims["nonce"] == nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	defer server.Close()
+	key, err := GenerateDPoPKey()
+	assert.Nil(t, err)
+
+	// DPoP transport records the nonce of retried responses, assert that:
+	// - the first request is retried once with the challenge nonce
+	// - later requests use the nonce of the latest response without a retry
+	client := &http.Client{Transport: NewDPoPTransport(key, server.URL+"/token", nil)}
+	for i := 0; i < 3; i++ {
+		resp, err := client.PostForm(server.URL+"/token", nil)
+		if assert.Nil(t, err) {
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+	}
+	assert.Equal(t, 4, requests)
+}
+
+func TestContext_MissingDPoPKey(t *testing.T) {
+	key, err := DPoPKeyFromContext(context.Background())
+	assert.Nil(t, key)
+	if assert.Error(t, err) {
+		assert.Equal(t, "oauth2: Context missing DPoP key", err.Error())
+	}
+}
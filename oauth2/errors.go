@@ -0,0 +1,20 @@
+package oauth2
+
+// AuthorizationError is an error response from an OAuth2 authorization
+// endpoint, such as when the user denies access (RFC 6749 4.1.2.1).
+type AuthorizationError struct {
+	// Code is the error code (e.g. "access_denied", "invalid_scope").
+	Code string
+	// Description is optional human-readable detail from the provider.
+	Description string
+	// URI optionally identifies a web page with information about the error.
+	URI string
+}
+
+// Error returns the error code and description.
+func (e *AuthorizationError) Error() string {
+	if e.Description == "" {
+		return "oauth2: authorization error " + e.Code
+	}
+	return "oauth2: authorization error " + e.Code + ": " + e.Description
+}
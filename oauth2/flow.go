@@ -0,0 +1,164 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/dghubble/gologin/v2/internal"
+)
+
+const (
+	// flowPrefix prefixes the flow ID in per-flow cookie names.
+	flowPrefix = "flow-"
+	// flowIDLen is the length of hex encoded flow IDs.
+	flowIDLen = 16
+	// defaultMaxFlows is the number of outstanding flows when no max is set.
+	defaultMaxFlows = 5
+)
+
+// FlowStateHandler issues a separate state cookie for each login flow, so
+// logins started in multiple tabs (with the same or different providers) do
+// not clobber each other's state. On login requests, a new flow ID is added
+// to the state and a (short-lived) state cookie named by the CookieConfig
+// name suffixed with "-flow-" and the flow ID is issued. On callback requests
+// (with a "state" parameter), the state cookie of that flow is read and
+// added to the ctx.
+//
+// At most maxFlows flows may be outstanding (default 5). Starting another
+// login expires the cookies of the oldest flows. PKCEHandler, NonceHandler,
+// and ReturnToHandler chained after FlowStateHandler key their cookies by
+// the flow ID as well.
+//
+// Use FlowStateHandler in place of StateHandler.
+func FlowStateHandler(config gologin.CookieConfig, maxFlows int, success http.Handler) http.Handler {
+	internal.MustValidConfig(config)
+	if maxFlows <= 0 {
+		maxFlows = defaultMaxFlows
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if state := req.FormValue("state"); state != "" {
+			// callback phase, read the state cookie of the flow
+			flowID := state
+			if len(flowID) > flowIDLen {
+				flowID = flowID[:flowIDLen]
+			}
+			var ownerState string
+			if validFlowID(flowID) {
+				flowConfig := internal.DerivedConfig(config, flowPrefix+flowID)
+				if value, err := internal.ReadCookie(req, flowConfig); err == nil {
+					var issuedFor string
+					_, ownerState, issuedFor = parseFlowValue(value)
+					if issuedFor != "" {
+						ctx = withStateProvider(ctx, issuedFor)
+					}
+				}
+				ctx = withFlowID(ctx, flowID)
+				ctx = withTempCookie(ctx, flowConfig)
+			}
+			// an empty state never matches, so CallbackHandler fails with
+			// ErrInvalidState for unknown flows
+			ctx = WithState(ctx, ownerState)
+			success.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// login phase, expire the oldest flows and start a new flow
+		expireOldFlows(w, req, config, maxFlows-1)
+		flowID := newFlowID()
+		state := flowID + randomState()
+		value := strconv.FormatInt(time.Now().Unix(), 10) + "|" + state
+		// record the provider the state was issued for
+		if name := providerName(ctx); name != "" {
+			value += "|" + name
+		}
+		http.SetCookie(w, internal.NewCookie(internal.DerivedConfig(config, flowPrefix+flowID), value))
+		ctx = withFlowID(ctx, flowID)
+		ctx = WithState(ctx, state)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// flowCookie is an outstanding flow's state cookie.
+type flowCookie struct {
+	name   string
+	issued int64
+}
+
+// expireOldFlows expires the state cookies (and cookies derived from them)
+// of the oldest outstanding flows, keeping at most max flows.
+func expireOldFlows(w http.ResponseWriter, req *http.Request, config gologin.CookieConfig, max int) {
+	prefix := config.Name + "-" + flowPrefix
+	var flows []flowCookie
+	for _, cookie := range req.Cookies() {
+		flowID := strings.TrimPrefix(cookie.Name, prefix)
+		if flowID == cookie.Name || !validFlowID(flowID) {
+			continue
+		}
+		flow := flowCookie{name: cookie.Name}
+		// unreadable state cookies are expired first
+		if value, err := internal.ReadCookie(req, internal.DerivedConfig(config, flowPrefix+flowID)); err == nil {
+			flow.issued, _, _ = parseFlowValue(value)
+		}
+		flows = append(flows, flow)
+	}
+	if len(flows) <= max {
+		return
+	}
+	sort.Slice(flows, func(i, j int) bool {
+		return flows[i].issued < flows[j].issued
+	})
+	for _, flow := range flows[:len(flows)-max] {
+		flowConfig := config
+		flowConfig.Name = flow.name
+		expireCookies(w, req, flowConfig)
+	}
+}
+
+// parseFlowValue parses a flow's state cookie value of the form
+// "issued|state" or "issued|state|provider".
+func parseFlowValue(value string) (issued int64, state, provider string) {
+	parts := strings.SplitN(value, "|", 3)
+	issued, _ = strconv.ParseInt(parts[0], 10, 64)
+	if len(parts) > 1 {
+		state = parts[1]
+	}
+	if len(parts) > 2 {
+		provider = parts[2]
+	}
+	return issued, state, provider
+}
+
+// derivedConfig returns the CookieConfig of a temporary cookie whose name is
+// the CookieConfig name suffixed with the flow ID in the ctx, if any, and
+// the given suffix.
+func derivedConfig(ctx context.Context, config gologin.CookieConfig, suffix string) gologin.CookieConfig {
+	if flowID, ok := flowIDFromContext(ctx); ok {
+		config = internal.DerivedConfig(config, flowPrefix+flowID)
+	}
+	return internal.DerivedConfig(config, suffix)
+}
+
+// newFlowID returns a random hex encoded flow ID.
+func newFlowID() string {
+	b := make([]byte, flowIDLen/2)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validFlowID returns true if the flow ID is hex encoded with the expected
+// length.
+func validFlowID(flowID string) bool {
+	if len(flowID) != flowIDLen {
+		return false
+	}
+	_, err := hex.DecodeString(flowID)
+	return err == nil
+}
@@ -0,0 +1,123 @@
+package oauth2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/dghubble/gologin/v2/internal"
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// startFlow runs the FlowStateHandler login phase (with PKCE) and returns
+// the state and issued cookies.
+func startFlow(t *testing.T, config gologin.CookieConfig, cookies []*http.Cookie) (string, []*http.Cookie) {
+	var state string
+	success := func(w http.ResponseWriter, req *http.Request) {
+		state, _ = StateFromContext(req.Context())
+	}
+	handler := FlowStateHandler(config, 2, PKCEHandler(config, http.HandlerFunc(success)))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/login", nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	handler.ServeHTTP(w, req)
+	return state, w.Result().Cookies()
+}
+
+func TestFlowStateHandler(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`)
+	defer server.Close()
+	config := gologin.DebugOnlyCookieConfig
+	oauth2Config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+
+	// FlowStateHandler login phase in two tabs, assert that:
+	// - each flow has a distinct state
+	// - state and PKCE cookies are keyed by the flow ID
+	state1, cookies1 := startFlow(t, config, nil)
+	state2, cookies2 := startFlow(t, config, cookies1)
+	assert.NotEqual(t, state1, state2)
+	if assert.Len(t, cookies1, 2) {
+		assert.Equal(t, "gologin-temporary-cookie-flow-"+state1[:16], cookies1[0].Name)
+		assert.Equal(t, "gologin-temporary-cookie-flow-"+state1[:16]+"-pkce", cookies1[1].Name)
+	}
+	assert.Len(t, cookies2, 2)
+
+	// FlowStateHandler callback phase of each flow with all cookies, assert that:
+	// - each flow validates against its own state
+	// - only the flow's cookies are expired
+	for _, state := range []string{state1, state2} {
+		success := func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(w, "success handler called")
+		}
+		handler := FlowStateHandler(config, 2, PKCEHandler(config, CallbackHandler(oauth2Config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/callback?code=any_code&state="+url.QueryEscape(state), nil)
+		for _, cookie := range append(cookies1, cookies2...) {
+			req.AddCookie(cookie)
+		}
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, "success handler called", w.Body.String())
+		expired := w.Result().Cookies()
+		if assert.Len(t, expired, 2) {
+			assert.True(t, strings.Contains(expired[0].Name, state[:16]))
+			assert.Equal(t, -1, expired[0].MaxAge)
+		}
+	}
+}
+
+func TestFlowStateHandler_UnknownFlow(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrInvalidState, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// FlowStateHandler callback phase without the flow's cookie, assert that:
+	// - the failure handler is called with ErrInvalidState
+	for _, state := range []string{"0123456789abcdef-state", "not-a-flow"} {
+		handler := FlowStateHandler(config, 0, CallbackHandler(&oauth2.Config{}, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure)))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/callback?code=any_code&state="+state, nil)
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, "failure handler called", w.Body.String())
+	}
+}
+
+func TestFlowStateHandler_MaxFlows(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	flowCookie := func(flowID string, issued int64) *http.Cookie {
+		return internal.NewCookie(internal.DerivedConfig(config, "flow-"+flowID), strconv.FormatInt(issued, 10)+"|"+flowID+"state")
+	}
+	existing := []*http.Cookie{
+		flowCookie("0000000000000002", 200),
+		flowCookie("0000000000000001", 100),
+		{Name: "gologin-temporary-cookie-flow-0000000000000001-pkce", Value: "verifier"},
+		{Name: "unrelated", Value: "value"},
+	}
+
+	// FlowStateHandler login phase with the max outstanding flows, assert that:
+	// - the oldest flow's cookies are expired
+	// - a new flow is started
+	state, cookies := startFlow(t, config, existing)
+	if assert.Len(t, cookies, 4) {
+		assert.Equal(t, "gologin-temporary-cookie-flow-0000000000000001", cookies[0].Name)
+		assert.Equal(t, -1, cookies[0].MaxAge)
+		assert.Equal(t, "gologin-temporary-cookie-flow-0000000000000001-pkce", cookies[1].Name)
+		assert.Equal(t, -1, cookies[1].MaxAge)
+		assert.Equal(t, "gologin-temporary-cookie-flow-"+state[:16], cookies[2].Name)
+		assert.Equal(t, 600, cookies[2].MaxAge)
+	}
+}
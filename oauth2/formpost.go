@@ -0,0 +1,70 @@
+package oauth2
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// formPostMarker is the form field added to re-POSTed callbacks.
+const formPostMarker = "gologin_form_post"
+
+// formPostTemplate auto-submits the callback parameters to the same URL.
+var formPostTemplate = template.Must(template.New("form_post").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Signing in</title></head>
+<body onload="document.forms[0].submit()">
+<form method="post" action="{{.Action}}">
+{{range $name, $values := .Params}}{{range $values}}<input type="hidden" name="{{$name}}" value="{{.}}">
+{{end}}{{end}}<input type="hidden" name="` + formPostMarker + `" value="1">
+<noscript><button type="submit">Continue</button></noscript>
+</form>
+</body>
+</html>
+`))
+
+// FormPostHandler accepts OAuth2 callbacks sent with response_mode=form_post
+// (e.g. by Apple, Microsoft, or OpenID Connect issuers). Providers POST these
+// callbacks cross-site, so browsers do not send SameSite Lax or Strict state
+// cookies with them. FormPostHandler responds to such POSTs with a page
+// which re-POSTs the same parameters to the same URL from the app's own
+// site, so the cookies are sent. Same-site POSTs and other requests are
+// passed to the success handler.
+//
+// Chain FormPostHandler before the StateHandler of the callback route, so
+// cookies are only read from the re-POSTed request. Request form_post
+// callbacks with WithAuthCodeOptions and a "response_mode" parameter.
+// Alternately, use a CookieConfig with SameSite None (which requires Secure
+// cookies) to receive state cookies with cross-site POSTs.
+func FormPostHandler(success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" || isSameSitePost(req) {
+			success.ServeHTTP(w, req)
+			return
+		}
+		if err := req.ParseForm(); err != nil {
+			http.Error(w, "oauth2: invalid form_post callback", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		formPostTemplate.Execute(w, struct {
+			Action string
+			Params map[string][]string
+		}{
+			Action: req.URL.Path,
+			Params: req.PostForm,
+		})
+	}
+	return http.HandlerFunc(fn)
+}
+
+// isSameSitePost returns true if the POST was re-POSTed by FormPostHandler
+// or the browser reports it was sent from the same site.
+func isSameSitePost(req *http.Request) bool {
+	switch req.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "same-site":
+		return true
+	}
+	return req.PostFormValue(formPostMarker) != ""
+}
@@ -0,0 +1,97 @@
+package oauth2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// hiddenInput matches the hidden inputs of the form_post page.
+var hiddenInput = regexp.MustCompile(`<input type="hidden" name="([^"]+)" value="([^"]*)">`)
+
+func postForm(target string, form url.Values) *http.Request {
+	req, _ := http.NewRequest("POST", target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestFormPostHandler(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`)
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	stateConfig := gologin.DebugOnlyCookieConfig
+	success := func(w http.ResponseWriter, req *http.Request) {
+		_, err := TokenFromContext(req.Context())
+		assert.Nil(t, err)
+		fmt.Fprintf(w, "success handler called")
+	}
+	handler := FormPostHandler(StateHandler(stateConfig, CallbackHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))))
+	stateCookie := &http.Cookie{Name: stateConfig.Name, Value: "d4e5f6"}
+
+	// cross-site form_post callback, which browsers send without the Lax
+	// state cookie, assert that:
+	// - no cookies are issued or read
+	// - a page re-POSTs the parameters to the same URL
+	w := httptest.NewRecorder()
+	req := postForm("/callback", url.Values{"code": {"any_code"}, "state": {"d4e5f6"}})
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Empty(t, w.Result().Cookies())
+	body := w.Body.String()
+	assert.Contains(t, body, `<form method="post" action="/callback">`)
+	form := url.Values{}
+	for _, match := range hiddenInput.FindAllStringSubmatch(body, -1) {
+		form.Add(match[1], match[2])
+	}
+	assert.Equal(t, url.Values{"code": {"any_code"}, "state": {"d4e5f6"}, "gologin_form_post": {"1"}}, form)
+
+	// same-site re-POST, which browsers send with the Lax state cookie,
+	// assert that:
+	// - the state cookie is delivered and validated
+	// - success handler is called
+	w = httptest.NewRecorder()
+	req = postForm("/callback", form)
+	req.Header.Set("Sec-Fetch-Site", "same-origin")
+	req.AddCookie(stateCookie)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestFormPostHandler_PassThrough(t *testing.T) {
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	handler := FormPostHandler(http.HandlerFunc(success))
+
+	// query callbacks and POSTs with the marker are passed through
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/callback?code=any_code&state=d4e5f6", nil),
+		postForm("/callback", url.Values{"code": {"any_code"}, "gologin_form_post": {"1"}}),
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, "success handler called", w.Body.String())
+	}
+}
+
+func TestFormPostHandler_Escaping(t *testing.T) {
+	handler := FormPostHandler(testutils.AssertSuccessNotCalled(t))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, postForm("/callback", url.Values{"state": {`"><script>alert(1)</script>`}}))
+	assert.NotContains(t, w.Body.String(), "<script>")
+}
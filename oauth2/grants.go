@@ -0,0 +1,159 @@
+package oauth2
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/dghubble/gologin/v2"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// RFC 8693 Token Exchange grant and token type identifiers.
+const (
+	TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	AccessTokenType        = "urn:ietf:params:oauth:token-type:access_token"
+	RefreshTokenType       = "urn:ietf:params:oauth:token-type:refresh_token"
+	IDTokenType            = "urn:ietf:params:oauth:token-type:id_token"
+	JWTTokenType           = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// ClientCredentialsHandler obtains a Token for the client itself with the
+// Client Credentials grant (RFC 6749 4.4), for machine identities which act
+// on their own behalf. The Token is reused until it expires. The Token and an
+// http.Client which authorizes requests with it are added to the ctx and the
+// success handler is called. If a Token cannot be obtained, the failure
+// handler is called.
+//
+// Token requests use the http.Client in the ctx under oauth2.HTTPClient, so
+// they may be authenticated with ClientAuthHandler or bound to a DPoPKey with
+// WithDPoP. DPoP-bound Tokens are only reused by requests with the same key,
+// so DPoPHandler (which generates a key per request) obtains a Token for
+// each request.
+func ClientCredentialsHandler(config *clientcredentials.Config, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	cache := &clientCredentialsCache{config: config}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := cache.Token(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithToken(ctx, token)
+		ctx = WithClient(ctx, oauth2.NewClient(ctx, oauth2.StaticTokenSource(token)))
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// clientCredentialsCache reuses a client credentials Token until it expires.
+// Tokens are only reused with the DPoPKey (if any) they are bound to.
+type clientCredentialsCache struct {
+	config *clientcredentials.Config
+
+	mu    sync.Mutex
+	token *oauth2.Token
+	key   *DPoPKey
+}
+
+func (c *clientCredentialsCache) Token(ctx context.Context) (*oauth2.Token, error) {
+	key, _ := DPoPKeyFromContext(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token.Valid() && c.key == key {
+		return c.token, nil
+	}
+	token, err := c.config.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.token = token
+	c.key = key
+	return token, nil
+}
+
+// TokenExchangeConfig describes an RFC 8693 Token Exchange, which exchanges
+// a subject token (e.g. a user's login Token) for a Token to call a
+// downstream service on the user's behalf.
+type TokenExchangeConfig struct {
+	// Config is the client's Config, whose ClientID, ClientSecret, and
+	// Endpoint TokenURL and AuthStyle are used. Its Scopes are not.
+	Config *oauth2.Config
+	// Audience is the logical name of the target service (optional).
+	Audience string
+	// Resource is the URL of the target service (optional).
+	Resource string
+	// Scopes are the requested scopes of the exchanged Token (optional).
+	Scopes []string
+	// RequestedTokenType is the requested token type identifier (e.g.
+	// AccessTokenType). Optional, the provider decides by default.
+	RequestedTokenType string
+}
+
+// Exchange exchanges the subject token of the given type identifier (e.g.
+// AccessTokenType) for a new Token. The issued token type identifier is
+// available as the Token's Extra("issued_token_type"). Requests use the
+// http.Client in the ctx under oauth2.HTTPClient, if any.
+func (c *TokenExchangeConfig) Exchange(ctx context.Context, subjectToken, subjectTokenType string) (*oauth2.Token, error) {
+	params := url.Values{
+		"grant_type":         {TokenExchangeGrantType},
+		"subject_token":      {subjectToken},
+		"subject_token_type": {subjectTokenType},
+	}
+	if c.Audience != "" {
+		params.Set("audience", c.Audience)
+	}
+	if c.Resource != "" {
+		params.Set("resource", c.Resource)
+	}
+	if c.RequestedTokenType != "" {
+		params.Set("requested_token_type", c.RequestedTokenType)
+	}
+	config := &clientcredentials.Config{
+		ClientID:     c.Config.ClientID,
+		ClientSecret: c.Config.ClientSecret,
+		TokenURL:     c.Config.Endpoint.TokenURL,
+		Scopes:       c.Scopes,
+		// clientcredentials allows the grant_type to be overridden
+		EndpointParams: params,
+		AuthStyle:      c.Config.Endpoint.AuthStyle,
+	}
+	return config.Token(ctx)
+}
+
+// TokenExchangeHandler exchanges the access token of the Token in the ctx
+// (e.g. added by CallbackHandler or RefreshHandler) for a downstream Token
+// with the TokenExchangeConfig. The exchanged Token replaces the Token in
+// the ctx, an http.Client which authorizes requests with it is added, and
+// the success handler is called. If the ctx has no Token or the exchange
+// fails, the failure handler is called.
+func TokenExchangeHandler(config *TokenExchangeConfig, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		subject, err := TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		token, err := config.Exchange(ctx, subject.AccessToken, AccessTokenType)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithToken(ctx, token)
+		ctx = WithClient(ctx, oauth2.NewClient(ctx, oauth2.StaticTokenSource(token)))
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,218 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/dghubble/gologin/v2/internal/jose"
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+func TestClientCredentialsHandler(t *testing.T) {
+	requests := 0
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		assert.Equal(t, "client_credentials", req.PostFormValue("grant_type"))
+		assert.Equal(t, "reports", req.PostFormValue("scope"))
+		assert.Equal(t, "client_secret", req.PostFormValue("client_secret"))
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"access_token":"machine_token","token_type":"Bearer","expires_in":3600}`))
+	})
+	defer server.Close()
+	config := &clientcredentials.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		TokenURL:     server.URL + "/token",
+		Scopes:       []string{"reports"},
+		AuthStyle:    oauth2.AuthStyleInParams,
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := TokenFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "machine_token", token.AccessToken)
+		_, err = ClientFromContext(ctx)
+		assert.Nil(t, err)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// ClientCredentialsHandler, assert that:
+	// - the Token and Client are added to the ctx
+	// - the Token is reused until it expires
+	handler := ClientCredentialsHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, "success handler called", w.Body.String())
+	}
+	assert.Equal(t, 1, requests)
+}
+
+func TestClientCredentialsHandler_DPoP(t *testing.T) {
+	// bound records the proof key X coordinate each token is bound to
+	bound := map[string]string{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		claims := verifyDPoPProof(t, req)
+		assert.NotNil(t, claims)
+		jwt, _ := jose.Parse(req.Header.Get("DPoP"))
+		token := fmt.Sprintf("machine_token_%d", len(bound))
+		bound[token] = jwt.Header.JWK.X
+		w.Header().Set(contentType, jsonContentType)
+		fmt.Fprintf(w, `{"access_token":%q,"token_type":"DPoP","expires_in":3600}`, token)
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, req *http.Request) {
+		verifyDPoPProof(t, req)
+		jwt, _ := jose.Parse(req.Header.Get("DPoP"))
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "DPoP ")
+		if bound[token] != jwt.Header.JWK.X {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	config := &clientcredentials.Config{
+		ClientID:  "client_id",
+		TokenURL:  server.URL + "/token",
+		AuthStyle: oauth2.AuthStyleInParams,
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		client, err := ClientFromContext(req.Context())
+		assert.Nil(t, err)
+		resp, err := client.Get(server.URL + "/api")
+		if assert.Nil(t, err) {
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// DPoPHandler before ClientCredentialsHandler, assert that:
+	// - each request's Token is bound to its own DPoP key
+	// - API requests are proven with the key the Token is bound to
+	handler := DPoPHandler(config.TokenURL, ClientCredentialsHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t)), nil)
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, "success handler called", w.Body.String())
+	}
+	assert.Len(t, bound, 2)
+
+	// WithDPoP with the same key, assert that:
+	// - the Token bound to the key is reused
+	key, err := GenerateDPoPKey()
+	assert.Nil(t, err)
+	handler = ClientCredentialsHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, req.WithContext(WithDPoP(req.Context(), config.TokenURL, key)))
+		assert.Equal(t, "success handler called", w.Body.String())
+	}
+	assert.Len(t, bound, 3)
+}
+
+func TestClientCredentialsHandler_Error(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(contentType, jsonContentType)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"invalid_client"}`))
+	})
+	defer server.Close()
+	config := &clientcredentials.Config{
+		ClientID:  "client_id",
+		TokenURL:  server.URL + "/token",
+		AuthStyle: oauth2.AuthStyleInParams,
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		var retrieveErr *oauth2.RetrieveError
+		if assert.ErrorAs(t, err, &retrieveErr) {
+			assert.Equal(t, "invalid_client", retrieveErr.ErrorCode)
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// ClientCredentialsHandler, assert that:
+	// - failure handler is called with the token endpoint error
+	handler := ClientCredentialsHandler(config, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestTokenExchangeHandler(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, TokenExchangeGrantType, req.PostFormValue("grant_type"))
+		assert.Equal(t, "user_token", req.PostFormValue("subject_token"))
+		assert.Equal(t, AccessTokenType, req.PostFormValue("subject_token_type"))
+		assert.Equal(t, "https://api.example.com", req.PostFormValue("audience"))
+		assert.Equal(t, "read", req.PostFormValue("scope"))
+		assert.Equal(t, AccessTokenType, req.PostFormValue("requested_token_type"))
+		assert.Equal(t, "", req.PostFormValue("resource"))
+		w.Header().Set(contentType, jsonContentType)
+		fmt.Fprintf(w, `{"access_token":"downstream_token","issued_token_type":%q,"token_type":"Bearer","expires_in":60}`, AccessTokenType)
+	})
+	defer server.Close()
+	config := &TokenExchangeConfig{
+		Config: &oauth2.Config{
+			ClientID:     "client_id",
+			ClientSecret: "client_secret",
+			Scopes:       []string{"login"},
+			Endpoint: oauth2.Endpoint{
+				TokenURL:  server.URL + "/token",
+				AuthStyle: oauth2.AuthStyleInParams,
+			},
+		},
+		Audience:           "https://api.example.com",
+		Scopes:             []string{"read"},
+		RequestedTokenType: AccessTokenType,
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		token, err := TokenFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "downstream_token", token.AccessToken)
+		assert.Equal(t, AccessTokenType, token.Extra("issued_token_type"))
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// TokenExchangeHandler with a Token in the ctx, assert that:
+	// - the Token is exchanged for a downstream Token
+	// - success handler is called with the exchanged Token
+	handler := TokenExchangeHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := WithToken(context.Background(), &oauth2.Token{AccessToken: "user_token"})
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTokenExchangeHandler_MissingToken(t *testing.T) {
+	config := &TokenExchangeConfig{Config: &oauth2.Config{}}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.Error(t, err) {
+			assert.Equal(t, "oauth2: Context missing Token", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// TokenExchangeHandler without a Token in the ctx, assert that:
+	// - failure handler is called
+	handler := TokenExchangeHandler(config, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
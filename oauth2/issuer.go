@@ -0,0 +1,92 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/gologin/v2"
+)
+
+// Mix-up defense errors
+var (
+	ErrMissingIssuer    = errors.New("oauth2: Callback missing iss parameter")
+	ErrIssuerMismatch   = errors.New("oauth2: Callback iss does not match the expected issuer")
+	ErrProviderMismatch = errors.New("oauth2: State was issued for another provider")
+)
+
+// IssuerHandler adds the expected authorization server issuer identifier to
+// the ctx. CallbackHandler then requires the callback "iss" parameter and
+// compares it with the issuer, rejecting missing values with
+// ErrMissingIssuer and others with ErrIssuerMismatch.
+//
+// Implements OAuth 2 RFC 9207 to defend against mix-up attacks when using
+// several providers. Only use IssuerHandler for providers which send the
+// "iss" parameter (e.g. OpenID Connect issuers advertising
+// authorization_response_iss_parameter_supported).
+func IssuerHandler(issuer string, success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := WithIssuer(req.Context(), issuer)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// checkIssuer compares the callback "iss" parameter with the expected issuer
+// in the ctx, if any.
+func checkIssuer(req *http.Request) error {
+	issuer, err := IssuerFromContext(req.Context())
+	if err != nil {
+		return nil
+	}
+	iss := req.Form.Get("iss")
+	if iss == "" {
+		return ErrMissingIssuer
+	}
+	if iss != issuer {
+		return ErrIssuerMismatch
+	}
+	return nil
+}
+
+// providerSuffix suffixes the name of the cookie which records the provider
+// a StateHandler state was issued for.
+const providerSuffix = "provider"
+
+// checkProvider returns ErrProviderMismatch if the ctx state was issued for a
+// provider other than the provider name in the ctx (see gologin.Registry).
+// States which do not record a provider are not checked.
+func checkProvider(ctx context.Context) error {
+	name := providerName(ctx)
+	if name == "" {
+		return nil
+	}
+	if issuedFor, ok := stateProviderFromContext(ctx); ok && issuedFor != name {
+		return ErrProviderMismatch
+	}
+	return nil
+}
+
+// storedState returns the StateStore value of a new state, recording the
+// provider name in the ctx, if any.
+func storedState(ctx context.Context, state string) string {
+	if name := providerName(ctx); name != "" {
+		return url.Values{"state": {state}, "provider": {name}}.Encode()
+	}
+	return state
+}
+
+// parseStoredState returns the state and provider name of a StateStore value.
+func parseStoredState(value string) (state, provider string) {
+	if values, err := url.ParseQuery(value); err == nil && values.Has("state") {
+		return values.Get("state"), values.Get("provider")
+	}
+	return value, ""
+}
+
+// providerName returns the provider name in the ctx, if any.
+func providerName(ctx context.Context) string {
+	name, _ := gologin.ProviderNameFromContext(ctx)
+	return name
+}
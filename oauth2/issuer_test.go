@@ -0,0 +1,216 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/dghubble/gologin/v2/store"
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestIssuerHandler(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`)
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	cases := []struct {
+		query string
+		err   error
+	}{
+		{"code=any_code&state=d4e5f6&iss=https%3A%2F%2Fserver.example.com", nil},
+		{"code=any_code&state=d4e5f6", ErrMissingIssuer},
+		{"code=any_code&state=d4e5f6&iss=https%3A%2F%2Fattacker.example.com", ErrIssuerMismatch},
+		// error responses are checked too
+		{"error=access_denied&state=d4e5f6&iss=https%3A%2F%2Fattacker.example.com", ErrIssuerMismatch},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()))
+			fmt.Fprintf(w, "failure handler called")
+		}
+		handler := IssuerHandler("https://server.example.com", CallbackHandler(config, http.HandlerFunc(success), http.HandlerFunc(failure)))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?"+c.query, nil)
+		handler.ServeHTTP(w, req.WithContext(WithState(context.Background(), "d4e5f6")))
+		if c.err == nil {
+			assert.Equal(t, "success handler called", w.Body.String())
+		} else {
+			assert.Equal(t, "failure handler called", w.Body.String())
+		}
+	}
+}
+
+func TestStateHandler_ProviderName(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	var state string
+	success := func(w http.ResponseWriter, req *http.Request) {
+		state, _ = StateFromContext(req.Context())
+	}
+	handler := StateHandler(config, http.HandlerFunc(success))
+
+	// StateHandler login for a named provider, assert that:
+	// - a provider cookie records the provider
+	// - the state does not encode the provider
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth/github/login", nil)
+	handler.ServeHTTP(w, req.WithContext(gologin.WithProviderName(req.Context(), "github")))
+	githubCookies := w.Result().Cookies()
+	if assert.Len(t, githubCookies, 2) {
+		assert.Equal(t, config.Name+"-provider", githubCookies[1].Name)
+		assert.Equal(t, "github", githubCookies[1].Value)
+	}
+	assert.NotContains(t, state, "github")
+	githubState := state
+
+	// StateHandler login for another provider with the first provider's
+	// cookies, assert that:
+	// - a new state is issued for the other provider
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/auth/google/login", nil)
+	for _, cookie := range githubCookies {
+		req.AddCookie(cookie)
+	}
+	handler.ServeHTTP(w, req.WithContext(gologin.WithProviderName(req.Context(), "google")))
+	assert.NotEqual(t, githubState, state)
+	if cookies := w.Result().Cookies(); assert.Len(t, cookies, 2) {
+		assert.Equal(t, "google", cookies[1].Value)
+	}
+}
+
+func TestCallbackHandler_ProviderMismatch(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrProviderMismatch, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// callback to the google route with the state of a github login, assert that:
+	// - failure handler is called with ErrProviderMismatch
+	handler := StateHandler(config, CallbackHandler(&oauth2.Config{}, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure)))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth/google/callback?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(&http.Cookie{Name: config.Name, Value: "d4e5f6"})
+	req.AddCookie(&http.Cookie{Name: config.Name + "-provider", Value: "github"})
+	handler.ServeHTTP(w, req.WithContext(gologin.WithProviderName(req.Context(), "google")))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestCallbackHandler_DottedState(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`)
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// CallbackHandler with a custom state containing a "." (e.g. a signed
+	// token), with and without a provider name in the ctx, assert that:
+	// - the state is not mistaken for a provider binding
+	// - success handler is called
+	for _, name := range []string{"", "github"} {
+		handler := CallbackHandler(config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/callback?code=any_code&state=abc.def", nil)
+		ctx := WithState(context.Background(), "abc.def")
+		if name != "" {
+			ctx = gologin.WithProviderName(ctx, name)
+		}
+		handler.ServeHTTP(w, req.WithContext(ctx))
+		assert.Equal(t, "success handler called", w.Body.String())
+	}
+}
+
+func TestStoreStateHandler_ProviderMismatch(t *testing.T) {
+	stateStore := store.NewMemoryStore(time.Minute)
+	var state string
+	loginSuccess := func(w http.ResponseWriter, req *http.Request) {
+		state, _ = StateFromContext(req.Context())
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrProviderMismatch, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth/github/login", nil)
+	StoreStateHandler(stateStore, http.HandlerFunc(loginSuccess), nil).ServeHTTP(w, req.WithContext(gologin.WithProviderName(req.Context(), "github")))
+	assert.NotContains(t, state, "github")
+
+	// stored state of a github login sent to the google callback, assert that:
+	// - failure handler is called with ErrProviderMismatch
+	handler := StoreStateHandler(stateStore, CallbackHandler(&oauth2.Config{}, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure)), nil)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/auth/google/callback?code=any_code&state="+url.QueryEscape(state), nil)
+	handler.ServeHTTP(w, req.WithContext(gologin.WithProviderName(req.Context(), "google")))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestSignedStateHandler_ProviderMismatch(t *testing.T) {
+	stateConfig := SignedStateConfig{Keys: [][]byte{[]byte("state-signing-key")}}
+	var state string
+	loginSuccess := func(w http.ResponseWriter, req *http.Request) {
+		state, _ = StateFromContext(req.Context())
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrProviderMismatch, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth/github/login", nil)
+	SignedStateHandler(stateConfig, http.HandlerFunc(loginSuccess)).ServeHTTP(w, req.WithContext(gologin.WithProviderName(req.Context(), "github")))
+
+	// signed state of a github login sent to the google callback
+	handler := SignedStateHandler(stateConfig, CallbackHandler(&oauth2.Config{}, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure)))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/auth/google/callback?code=any_code&state="+url.QueryEscape(state), nil)
+	handler.ServeHTTP(w, req.WithContext(gologin.WithProviderName(req.Context(), "google")))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestFlowStateHandler_ProviderMismatch(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	var state string
+	loginSuccess := func(w http.ResponseWriter, req *http.Request) {
+		state, _ = StateFromContext(req.Context())
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrProviderMismatch, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth/github/login", nil)
+	FlowStateHandler(config, 0, http.HandlerFunc(loginSuccess)).ServeHTTP(w, req.WithContext(gologin.WithProviderName(req.Context(), "github")))
+	flowCookies := w.Result().Cookies()
+
+	// flow state of a github login sent to the google callback, assert that:
+	// - failure handler is called with ErrProviderMismatch
+	handler := FlowStateHandler(config, 0, CallbackHandler(&oauth2.Config{}, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure)))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/auth/google/callback?code=any_code&state="+url.QueryEscape(state), nil)
+	for _, cookie := range flowCookies {
+		req.AddCookie(cookie)
+	}
+	handler.ServeHTTP(w, req.WithContext(gologin.WithProviderName(req.Context(), "google")))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
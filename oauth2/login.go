@@ -1,6 +1,7 @@
 package oauth2
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
@@ -14,36 +15,190 @@ import (
 // Errors which may occur on login.
 var (
 	ErrInvalidState = errors.New("oauth2: Invalid OAuth2 state parameter")
+	ErrStateExpired = errors.New("oauth2: Expired OAuth2 state parameter")
 )
 
 // StateHandler checks for a state cookie. If found, the state value is read
 // and added to the ctx. Otherwise, a non-guessable value is added to the ctx
-// and to a (short-lived) state cookie issued to the requester.
+// and to a (short-lived) state cookie issued to the requester. The state
+// cookie is expired by CallbackHandler, so each state is used only once.
 //
 // Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
 // state params differently, write a http.Handler which sets the ctx state,
 // using oauth2 WithState(ctx, state) since it is required by LoginHandler
 // and CallbackHandler.
 func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return stateHandler(config, false, success)
+}
+
+// RotatingStateHandler is a StateHandler which always issues a new state
+// cookie, replacing any state left by an abandoned login. Use it for login
+// requests only, and a StateHandler for callback requests.
+func RotatingStateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return stateHandler(config, true, success)
+}
+
+func stateHandler(config gologin.CookieConfig, rotate bool, success http.Handler) http.Handler {
+	internal.MustValidConfig(config)
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
-		cookie, err := req.Cookie(config.Name)
-		if err == nil {
+		providerConfig := internal.DerivedConfig(config, providerSuffix)
+		value, err := internal.ReadCookie(req, config)
+		issuedFor, providerErr := internal.ReadCookie(req, providerConfig)
+		// login requests for another provider need a new state
+		reuse := req.FormValue("state") != "" || issuedFor == providerName(ctx)
+		if err == nil && !rotate && reuse {
 			// add the cookie state to the ctx
-			ctx = WithState(ctx, cookie.Value)
+			ctx = WithState(ctx, value)
 		} else {
 			// add Cookie with a random state
 			val := randomState()
 			http.SetCookie(w, internal.NewCookie(config, val))
 			ctx = WithState(ctx, val)
+			// record the provider the state was issued for
+			issuedFor = providerName(ctx)
+			if issuedFor != "" {
+				http.SetCookie(w, internal.NewCookie(providerConfig, issuedFor))
+			} else if providerErr == nil {
+				providerConfig.MaxAge = -1
+				http.SetCookie(w, internal.NewCookie(providerConfig, ""))
+			}
+		}
+		ctx = withTempCookie(ctx, config)
+		if issuedFor != "" {
+			ctx = withStateProvider(ctx, issuedFor)
+			ctx = withTempCookie(ctx, providerConfig)
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// StoreStateHandler issues and checks state values kept in a StateStore. On
+// login requests, a non-guessable state is saved to the store (keyed by
+// itself) and added to the ctx. On callback requests (with a "state"
+// parameter), the stored state is loaded, deleted so it may only be used
+// once, and added to the ctx. If the state cannot be loaded or was already
+// deleted (e.g. by a concurrent callback), the failure handler is called.
+//
+// Use StoreStateHandler in place of StateHandler to keep state server-side.
+func StoreStateHandler(store gologin.StateStore, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if state := req.FormValue("state"); state != "" {
+			// callback phase, load and delete the state from the store
+			val, err := store.Load(req, state)
+			if err != nil {
+				if errors.Is(err, gologin.ErrStateNotFound) || errors.Is(err, gologin.ErrInvalidCookie) {
+					err = ErrInvalidState
+				}
+				ctx = gologin.WithError(ctx, err)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			if err := store.Delete(w, req, state); err != nil {
+				if errors.Is(err, gologin.ErrStateNotFound) {
+					err = ErrInvalidState
+				}
+				ctx = gologin.WithError(ctx, err)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+			val, issuedFor := parseStoredState(val)
+			ctx = WithState(ctx, val)
+			if issuedFor != "" {
+				ctx = withStateProvider(ctx, issuedFor)
+			}
+			success.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// login phase, save a random state to the store
+		val := randomState()
+		if err := store.Save(w, req, val, storedState(ctx, val)); err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithState(ctx, val)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// PKCEHandler checks for a PKCE verifier cookie. If found, the verifier is
+// read and added to the ctx. Otherwise, a new code verifier is added to the
+// ctx and to a (short-lived) verifier cookie issued to the requester. The
+// cookie name is the CookieConfig name suffixed with "-pkce", so the same
+// CookieConfig given to StateHandler may be used.
+//
+// Implements OAuth 2 PKCE RFC 7636. When a verifier is present in the ctx,
+// LoginHandler sends its S256 code_challenge and CallbackHandler sends the
+// code_verifier in the token exchange.
+func PKCEHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	internal.MustValidConfig(config)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		config := derivedConfig(ctx, config, "pkce")
+		value, err := internal.ReadCookie(req, config)
+		if err == nil {
+			// add the cookie verifier to the ctx
+			ctx = WithVerifier(ctx, value)
+		} else {
+			// add Cookie with a new verifier
+			val := oauth2.GenerateVerifier()
+			http.SetCookie(w, internal.NewCookie(config, val))
+			ctx = WithVerifier(ctx, val)
+		}
+		ctx = withTempCookie(ctx, config)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// NonceHandler checks for a nonce cookie. If found, the nonce value is read
+// and added to the ctx. Otherwise, a non-guessable nonce is added to the ctx
+// and to a (short-lived) nonce cookie issued to the requester. The cookie
+// name is the CookieConfig name suffixed with "-nonce", so the same
+// CookieConfig given to StateHandler may be used.
+//
+// When a nonce is present in the ctx, LoginHandler sends it as the OpenID
+// Connect "nonce" parameter, binding issued ID tokens to the browser session.
+// Callback handlers may compare the ID token nonce claim with
+// NonceFromContext.
+func NonceHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	internal.MustValidConfig(config)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		config := derivedConfig(ctx, config, "nonce")
+		value, err := internal.ReadCookie(req, config)
+		if err == nil {
+			// add the cookie nonce to the ctx
+			ctx = WithNonce(ctx, value)
+		} else {
+			// add Cookie with a random nonce
+			val := randomState()
+			http.SetCookie(w, internal.NewCookie(config, val))
+			ctx = WithNonce(ctx, val)
 		}
+		ctx = withTempCookie(ctx, config)
 		success.ServeHTTP(w, req.WithContext(ctx))
 	}
 	return http.HandlerFunc(fn)
 }
 
 // LoginHandler handles OAuth2 login requests by reading the state value from
-// the ctx and redirecting requests to the AuthURL with that state value.
+// the ctx and redirecting requests to the AuthURL with that state value. If
+// the ctx contains a PKCE verifier, its S256 code challenge is included. If
+// the ctx contains a nonce, it is included as the "nonce" parameter. Any
+// AuthCodeOptions added to the ctx with WithAuthCodeOptions are applied.
+//
+// If the ctx has a Pushed Authorization Request endpoint URL (see
+// PushedAuthHandler), the parameters are POSTed to it instead and requests
+// are redirected to the AuthURL with only the client_id and the issued
+// request_uri. Push failures are added to the ctx of the failure handler.
 func LoginHandler(config *oauth2.Config, failure http.Handler) http.Handler {
 	if failure == nil {
 		failure = gologin.DefaultFailureHandler
@@ -56,7 +211,22 @@ func LoginHandler(config *oauth2.Config, failure http.Handler) http.Handler {
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
-		authURL := config.AuthCodeURL(state)
+		opts := AuthCodeOptionsFromContext(ctx)
+		if verifier, err := VerifierFromContext(ctx); err == nil {
+			opts = append(opts, oauth2.S256ChallengeOption(verifier))
+		}
+		if nonce, err := NonceFromContext(ctx); err == nil {
+			opts = append(opts, oauth2.SetAuthURLParam("nonce", nonce))
+		}
+		authURL := config.AuthCodeURL(state, opts...)
+		if pushedAuthURL, err := PushedAuthURLFromContext(ctx); err == nil {
+			authURL, err = pushAuthRequest(ctx, config, pushedAuthURL, authURL)
+			if err != nil {
+				ctx = gologin.WithError(ctx, err)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+		}
 		http.Redirect(w, req, authURL, http.StatusFound)
 	}
 	return http.HandlerFunc(fn)
@@ -64,32 +234,57 @@ func LoginHandler(config *oauth2.Config, failure http.Handler) http.Handler {
 
 // CallbackHandler handles OAuth2 redirection URI requests by parsing the auth
 // code and state, comparing with the state value from the ctx, and obtaining
-// an OAuth2 Token.
+// an OAuth2 Token. If the ctx contains a PKCE verifier, it is sent with the
+// token exchange. Temporary cookies issued for the flow (e.g. by
+// StateHandler) are expired whether the callback succeeds or fails. If the
+// ctx state was issued by SignedStateHandler, the state is verified instead
+// and its payload added to the ctx.
+//
+// If the provider redirects with an error response (e.g. the user denied
+// access) whose state matches, an *AuthorizationError is added to the ctx of
+// the failure handler.
+//
+// If the ctx has an expected issuer (see IssuerHandler), the callback "iss"
+// parameter must match it. If the ctx has a provider name (see
+// gologin.Registry), the state must have been issued for that provider, or
+// ErrProviderMismatch is added to the ctx of the failure handler.
 func CallbackHandler(config *oauth2.Config, success, failure http.Handler) http.Handler {
 	if failure == nil {
 		failure = gologin.DefaultFailureHandler
 	}
 	fn := func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
+		expireTempCookies(ctx, w)
 		authCode, state, err := parseCallback(req)
-		if err != nil {
+		var authErr *AuthorizationError
+		if err != nil && !errors.As(err, &authErr) {
 			ctx = gologin.WithError(ctx, err)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
-		ownerState, err := StateFromContext(ctx)
+		if err := checkIssuer(req); err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx, err = checkState(req.WithContext(ctx), state)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
-		if state != ownerState || state == "" {
-			ctx = gologin.WithError(ctx, ErrInvalidState)
+		// error response from the provider, validated by its state
+		if authErr != nil {
+			ctx = gologin.WithError(ctx, authErr)
 			failure.ServeHTTP(w, req.WithContext(ctx))
 			return
 		}
+		var opts []oauth2.AuthCodeOption
+		if verifier, err := VerifierFromContext(ctx); err == nil {
+			opts = append(opts, oauth2.VerifierOption(verifier))
+		}
 		// use the authorization code to get a Token
-		token, err := config.Exchange(ctx, authCode)
+		token, err := config.Exchange(ctx, authCode, opts...)
 		if err != nil {
 			ctx = gologin.WithError(ctx, err)
 			failure.ServeHTTP(w, req.WithContext(ctx))
@@ -101,6 +296,64 @@ func CallbackHandler(config *oauth2.Config, success, failure http.Handler) http.
 	return http.HandlerFunc(fn)
 }
 
+// ValidateStateHandler checks the state parameter of a redirect which does
+// not carry an authorization code (e.g. an OpenID Connect post logout
+// redirect) against the ctx state, like CallbackHandler. Temporary cookies
+// issued for the flow are expired. If the state is valid, handling delegates
+// to the success handler, otherwise to the failure handler.
+func ValidateStateHandler(success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		expireTempCookies(ctx, w)
+		ctx, err := checkState(req, req.FormValue("state"))
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// checkState compares a redirect's state with the state value from the ctx,
+// or verifies it if the ctx state was issued by SignedStateHandler, and
+// returns the ctx with any signed state payload added.
+func checkState(req *http.Request, state string) (context.Context, error) {
+	ctx := req.Context()
+	if verify, ok := stateVerifierFromContext(ctx); ok {
+		// signed state, verified rather than compared
+		payload, err := verify(req, state)
+		if err != nil {
+			return ctx, err
+		}
+		return WithStatePayload(ctx, payload), nil
+	}
+	ownerState, err := StateFromContext(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	if state != ownerState || state == "" {
+		return ctx, ErrInvalidState
+	}
+	// a state issued for another provider indicates a mix-up
+	if err := checkProvider(ctx); err != nil {
+		return ctx, err
+	}
+	return ctx, nil
+}
+
+// expireTempCookies expires the temporary cookies recorded in the ctx.
+func expireTempCookies(ctx context.Context, w http.ResponseWriter) {
+	for _, config := range tempCookiesFromContext(ctx) {
+		config.MaxAge = -1
+		http.SetCookie(w, internal.NewCookie(config, ""))
+	}
+}
+
 // Returns a base64 encoded random 32 byte string.
 func randomState() string {
 	b := make([]byte, 32)
@@ -109,7 +362,8 @@ func randomState() string {
 }
 
 // parseCallback parses the "code" and "state" parameters from the http.Request
-// and returns them.
+// and returns them. If the request is an error response, the state and an
+// *AuthorizationError are returned.
 func parseCallback(req *http.Request) (authCode, state string, err error) {
 	err = req.ParseForm()
 	if err != nil {
@@ -117,6 +371,13 @@ func parseCallback(req *http.Request) (authCode, state string, err error) {
 	}
 	authCode = req.Form.Get("code")
 	state = req.Form.Get("state")
+	if code := req.Form.Get("error"); code != "" {
+		return "", state, &AuthorizationError{
+			Code:        code,
+			Description: req.Form.Get("error_description"),
+			URI:         req.Form.Get("error_uri"),
+		}
+	}
 	if authCode == "" || state == "" {
 		return "", "", errors.New("oauth2: Request missing code or state")
 	}
@@ -5,15 +5,299 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/dghubble/gologin/v2"
+	"github.com/dghubble/gologin/v2/store"
 	"github.com/dghubble/gologin/v2/testutils"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/oauth2"
 )
 
+// StateHandler
+
+func TestStateHandler(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	success := func(w http.ResponseWriter, req *http.Request) {
+		state, err := StateFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.NotEqual(t, "", state)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// StateHandler called without a state cookie, assert that:
+	// - a state cookie is issued
+	// - the state is added to the ctx of the success handler
+	handler := StateHandler(config, http.HandlerFunc(success))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "gologin-temporary-cookie", cookies[0].Name)
+	}
+}
+
+func TestStateHandler_ExistingCookie(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	success := func(w http.ResponseWriter, req *http.Request) {
+		state, err := StateFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "existing-state", state)
+	}
+
+	// StateHandler called with a state cookie, assert that:
+	// - no new cookie is issued
+	// - the cookie state is added to the ctx
+	handler := StateHandler(config, http.HandlerFunc(success))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "gologin-temporary-cookie", Value: "existing-state"})
+	handler.ServeHTTP(w, req)
+	assert.Empty(t, w.Result().Cookies())
+}
+
+func TestRotatingStateHandler(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	var issued string
+	success := func(w http.ResponseWriter, req *http.Request) {
+		state, err := StateFromContext(req.Context())
+		assert.Nil(t, err)
+		issued = state
+	}
+
+	// RotatingStateHandler called with a state cookie, assert that:
+	// - a new state cookie replaces it
+	// - the new state is added to the ctx
+	handler := RotatingStateHandler(config, http.HandlerFunc(success))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "gologin-temporary-cookie", Value: "existing-state"})
+	handler.ServeHTTP(w, req)
+	assert.NotEqual(t, "existing-state", issued)
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, issued, cookies[0].Value)
+	}
+}
+
+// StoreStateHandler
+
+func TestStoreStateHandler(t *testing.T) {
+	stateStore := store.NewMemoryStore(time.Minute)
+	var issued string
+	loginSuccess := func(w http.ResponseWriter, req *http.Request) {
+		state, err := StateFromContext(req.Context())
+		assert.Nil(t, err)
+		issued = state
+	}
+	callbackSuccess := func(w http.ResponseWriter, req *http.Request) {
+		state, err := StateFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, issued, state)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// StoreStateHandler login phase, assert that:
+	// - a state is saved to the store and added to the ctx
+	// - no cookie is issued by the memory store
+	handler := StoreStateHandler(stateStore, http.HandlerFunc(loginSuccess), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/login", nil)
+	handler.ServeHTTP(w, req)
+	assert.NotEqual(t, "", issued)
+	assert.Empty(t, w.Result().Cookies())
+
+	// StoreStateHandler callback phase, assert that:
+	// - the stored state is added to the ctx
+	handler = StoreStateHandler(stateStore, http.HandlerFunc(callbackSuccess), failure)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/callback?code=any_code&state="+issued, nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestStoreStateHandler_SingleUse(t *testing.T) {
+	stateStore := store.NewMemoryStore(time.Minute)
+	req, _ := http.NewRequest("GET", "/", nil)
+	stateStore.Save(httptest.NewRecorder(), req, "d4e5f6", "d4e5f6")
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.Equal(t, ErrInvalidState, err)
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// StoreStateHandler callback phase twice with the same state, assert that:
+	// - the first callback succeeds
+	// - the replayed callback calls the failure handler with ErrInvalidState
+	handler := StoreStateHandler(stateStore, http.HandlerFunc(success), http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/callback?code=any_code&state=d4e5f6", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+// racingStore is a StateStore whose Loads wait until every racing callback
+// has loaded the value, before any of them may delete it.
+type racingStore struct {
+	gologin.StateStore
+	loaded sync.WaitGroup
+}
+
+func (s *racingStore) Load(req *http.Request, key string) (string, error) {
+	value, err := s.StateStore.Load(req, key)
+	s.loaded.Done()
+	s.loaded.Wait()
+	return value, err
+}
+
+func TestStoreStateHandler_ConcurrentCallbacks(t *testing.T) {
+	const callbacks = 2
+	stateStore := &racingStore{StateStore: store.NewMemoryStore(time.Minute)}
+	stateStore.loaded.Add(callbacks)
+	req, _ := http.NewRequest("GET", "/", nil)
+	stateStore.Save(httptest.NewRecorder(), req, "d4e5f6", "d4e5f6")
+
+	var successes, failures int32
+	success := func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&successes, 1)
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.Equal(t, ErrInvalidState, err)
+		atomic.AddInt32(&failures, 1)
+	}
+
+	// StoreStateHandler callbacks with the same state which both load it
+	// before either deletes it, assert that:
+	// - only one callback succeeds
+	// - the other calls the failure handler with ErrInvalidState
+	handler := StoreStateHandler(stateStore, http.HandlerFunc(success), http.HandlerFunc(failure))
+	var wg sync.WaitGroup
+	for i := 0; i < callbacks; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "/callback?code=any_code&state=d4e5f6", nil)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), successes)
+	assert.Equal(t, int32(callbacks-1), failures)
+}
+
+// PKCEHandler
+
+func TestPKCEHandler(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	success := func(w http.ResponseWriter, req *http.Request) {
+		verifier, err := VerifierFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.NotEqual(t, "", verifier)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// PKCEHandler called without a verifier cookie, assert that:
+	// - a verifier cookie is issued, named after the CookieConfig
+	// - the verifier is added to the ctx of the success handler
+	handler := PKCEHandler(config, http.HandlerFunc(success))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "gologin-temporary-cookie-pkce", cookies[0].Name)
+		assert.Len(t, cookies[0].Value, 43)
+	}
+}
+
+func TestPKCEHandler_ExistingCookie(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	success := func(w http.ResponseWriter, req *http.Request) {
+		verifier, err := VerifierFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "existing-verifier", verifier)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// PKCEHandler called with a verifier cookie, assert that:
+	// - no new cookie is issued
+	// - the cookie verifier is added to the ctx of the success handler
+	handler := PKCEHandler(config, http.HandlerFunc(success))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "gologin-temporary-cookie-pkce", Value: "existing-verifier"})
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.Empty(t, w.Result().Cookies())
+}
+
+// NonceHandler
+
+func TestNonceHandler(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	var issued string
+	success := func(w http.ResponseWriter, req *http.Request) {
+		nonce, err := NonceFromContext(req.Context())
+		assert.Nil(t, err)
+		issued = nonce
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// NonceHandler called without a nonce cookie, assert that:
+	// - a nonce cookie is issued, named after the CookieConfig
+	// - the nonce is added to the ctx of the success handler
+	handler := NonceHandler(config, http.HandlerFunc(success))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "gologin-temporary-cookie-nonce", cookies[0].Name)
+		assert.Equal(t, issued, cookies[0].Value)
+		assert.NotEqual(t, "", issued)
+	}
+}
+
+func TestNonceHandler_ExistingCookie(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	success := func(w http.ResponseWriter, req *http.Request) {
+		nonce, err := NonceFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "existing-nonce", nonce)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// NonceHandler called with a nonce cookie, assert that:
+	// - no new cookie is issued
+	// - the cookie nonce is added to the ctx of the success handler
+	handler := NonceHandler(config, http.HandlerFunc(success))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "gologin-temporary-cookie-nonce", Value: "existing-nonce"})
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.Empty(t, w.Result().Cookies())
+}
+
 // LoginHandler
 
 func TestLoginHandler(t *testing.T) {
@@ -42,6 +326,83 @@ func TestLoginHandler(t *testing.T) {
 	assert.Equal(t, expectedRedirect, w.Result().Header.Get("Location"))
 }
 
+func TestLoginHandler_PKCE(t *testing.T) {
+	verifier := oauth2.GenerateVerifier()
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL: "https://api.example.com/authorize",
+		},
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler with a verifier in the ctx, assert that:
+	// - redirect url includes the S256 code challenge of the verifier
+	loginHandler := LoginHandler(config, failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := WithState(context.Background(), "state_val")
+	ctx = WithVerifier(ctx, verifier)
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Result().Header.Get("Location"))
+	assert.Nil(t, err)
+	assert.Equal(t, "S256", location.Query().Get("code_challenge_method"))
+	assert.Equal(t, oauth2.S256ChallengeFromVerifier(verifier), location.Query().Get("code_challenge"))
+}
+
+func TestLoginHandler_Nonce(t *testing.T) {
+	expectedRedirect := "https://api.example.com/authorize?client_id=client_id&nonce=nonce_val&response_type=code&state=state_val"
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL: "https://api.example.com/authorize",
+		},
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// LoginHandler with a nonce in the ctx, assert that:
+	// - redirect url includes the nonce parameter
+	loginHandler := LoginHandler(config, failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := WithState(context.Background(), "state_val")
+	ctx = WithNonce(ctx, "nonce_val")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, expectedRedirect, w.Result().Header.Get("Location"))
+}
+
+func TestLoginHandler_AuthCodeOptions(t *testing.T) {
+	expectedRedirect := "https://api.example.com/authorize?access_type=offline&client_id=client_id&login_hint=user%40example.com&prompt=consent&response_type=code&state=state_val"
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL: "https://api.example.com/authorize",
+		},
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+	// upstream handler adds options based on the request
+	options := func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, req *http.Request) {
+			ctx := WithAuthCodeOptions(req.Context(), oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("prompt", "consent"))
+			ctx = WithAuthCodeOptions(ctx, oauth2.SetAuthURLParam("login_hint", req.FormValue("email")))
+			next.ServeHTTP(w, req.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+
+	// LoginHandler with AuthCodeOptions in the ctx, assert that:
+	// - redirect url includes the option parameters
+	loginHandler := options(LoginHandler(config, failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?email=user%40example.com", nil)
+	ctx := WithState(context.Background(), "state_val")
+	loginHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, expectedRedirect, w.Result().Header.Get("Location"))
+}
+
 func TestLoginHandler_MissingCtxState(t *testing.T) {
 	config := &oauth2.Config{}
 	failure := func(w http.ResponseWriter, req *http.Request) {
@@ -109,6 +470,83 @@ func TestCallbackHandler(t *testing.T) {
 	assert.Equal(t, "success handler called", w.Body.String())
 }
 
+func TestCallbackHandler_PKCE(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "verifier_val", req.PostFormValue("code_verifier"))
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`))
+	})
+	defer server.Close()
+
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		_, err := TokenFromContext(req.Context())
+		assert.Nil(t, err)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler with a verifier in the ctx, assert that:
+	// - the code verifier is sent to the token endpoint
+	// - success handler is called
+	callbackHandler := CallbackHandler(config, http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	ctx := WithState(context.Background(), "d4e5f6")
+	ctx = WithVerifier(ctx, "verifier_val")
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestCallbackHandler_ExpiresTempCookies(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`)
+	defer server.Close()
+
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "failure handler called")
+	}
+	stateConfig := gologin.DebugOnlyCookieConfig
+	handler := StateHandler(stateConfig, PKCEHandler(stateConfig, CallbackHandler(config, http.HandlerFunc(success), http.HandlerFunc(failure))))
+
+	// CallbackHandler succeeds or fails, assert that:
+	// - the state and PKCE cookies are expired
+	for _, test := range []struct {
+		state    string
+		expected string
+	}{
+		{"d4e5f6", "success handler called"},
+		{"replayed", "failure handler called"},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?code=any_code&state="+test.state, nil)
+		req.AddCookie(&http.Cookie{Name: "gologin-temporary-cookie", Value: "d4e5f6"})
+		req.AddCookie(&http.Cookie{Name: "gologin-temporary-cookie-pkce", Value: "verifier"})
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, test.expected, w.Body.String())
+		cookies := w.Result().Cookies()
+		if assert.Len(t, cookies, 2) {
+			assert.Equal(t, "gologin-temporary-cookie", cookies[0].Name)
+			assert.Equal(t, "gologin-temporary-cookie-pkce", cookies[1].Name)
+			for _, cookie := range cookies {
+				assert.Equal(t, -1, cookie.MaxAge)
+				assert.Equal(t, "", cookie.Value)
+			}
+		}
+	}
+}
+
 func TestCallbackHandler_ParseCallbackError(t *testing.T) {
 	config := &oauth2.Config{}
 	success := testutils.AssertSuccessNotCalled(t)
@@ -136,6 +574,52 @@ func TestCallbackHandler_ParseCallbackError(t *testing.T) {
 	assert.Equal(t, "failure handler called", w.Body.String())
 }
 
+func TestCallbackHandler_AuthorizationError(t *testing.T) {
+	config := &oauth2.Config{}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		var authErr *AuthorizationError
+		if assert.ErrorAs(t, err, &authErr) {
+			assert.Equal(t, "access_denied", authErr.Code)
+			assert.Equal(t, "The user denied access", authErr.Description)
+			assert.Equal(t, "https://example.com/errors", authErr.URI)
+			assert.Equal(t, "oauth2: authorization error access_denied: The user denied access", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler called with an error response, assert that:
+	// - failure handler is called
+	// - AuthorizationError with the error code, description, and uri is added to the ctx
+	callbackHandler := CallbackHandler(config, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?error=access_denied&error_description=The+user+denied+access&error_uri=https%3A%2F%2Fexample.com%2Ferrors&state=d4e5f6", nil)
+	ctx := WithState(context.Background(), "d4e5f6")
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestCallbackHandler_AuthorizationErrorStateMismatch(t *testing.T) {
+	config := &oauth2.Config{}
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.Equal(t, ErrInvalidState, err)
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// CallbackHandler called with an error response for another state, assert that:
+	// - failure handler is called
+	// - error about invalid state param is added to the ctx
+	callbackHandler := CallbackHandler(config, success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?error=access_denied&state=forged", nil)
+	ctx := WithState(context.Background(), "d4e5f6")
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
 func TestCallbackHandler_MissingCtxState(t *testing.T) {
 	config := &oauth2.Config{}
 	success := testutils.AssertSuccessNotCalled(t)
@@ -211,3 +695,47 @@ func TestCallbackHandler_ExchangeError(t *testing.T) {
 	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
 	assert.Equal(t, "failure handler called", w.Body.String())
 }
+
+func TestValidateStateHandler(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrInvalidState, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+	handler := StateHandler(config, ValidateStateHandler(http.HandlerFunc(success), http.HandlerFunc(failure)))
+
+	// ValidateStateHandler with a matching state, assert that:
+	// - the state cookie is expired
+	// - success handler is called
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?state=state_val", nil)
+	req.AddCookie(&http.Cookie{Name: config.Name, Value: "state_val"})
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	if cookies := w.Result().Cookies(); assert.Len(t, cookies, 1) {
+		assert.Equal(t, -1, cookies[0].MaxAge)
+	}
+
+	// ValidateStateHandler with a missing state, assert that:
+	// - failure handler is called with ErrInvalidState
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: config.Name, Value: "state_val"})
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestStateHandler_InvalidEncryptionKey(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	config.EncryptionKeys = [][]byte{[]byte("short")}
+
+	// handlers reject invalid CookieConfig keys when they are built
+	success := testutils.AssertSuccessNotCalled(t)
+	assert.Panics(t, func() { StateHandler(config, success) })
+	assert.Panics(t, func() { PKCEHandler(config, success) })
+	assert.Panics(t, func() { NonceHandler(config, success) })
+	assert.Panics(t, func() { FlowStateHandler(config, 0, success) })
+}
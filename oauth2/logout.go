@@ -0,0 +1,137 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/dghubble/gologin/v2/internal"
+	"golang.org/x/oauth2"
+)
+
+// Revoker revokes a user's OAuth2 Token with a provider.
+type Revoker interface {
+	Revoke(ctx context.Context, token *oauth2.Token) error
+}
+
+// RevokerFunc is an adapter to allow the use of ordinary functions as
+// Revokers.
+type RevokerFunc func(ctx context.Context, token *oauth2.Token) error
+
+// Revoke calls f(ctx, token).
+func (f RevokerFunc) Revoke(ctx context.Context, token *oauth2.Token) error {
+	return f(ctx, token)
+}
+
+// NewRevoker returns a Revoker which revokes Tokens at an OAuth 2 Token
+// Revocation (RFC 7009) endpoint. The refresh token is revoked if present
+// (which revokes its access tokens with most providers), otherwise the
+// access token. The client authenticates with HTTP Basic auth, or sends its
+// client_id if it has no secret. Requests use the http.Client in the ctx
+// under oauth2.HTTPClient, if any.
+func NewRevoker(config *oauth2.Config, revocationURL string) Revoker {
+	fn := func(ctx context.Context, token *oauth2.Token) error {
+		data := url.Values{}
+		if token.RefreshToken != "" {
+			data.Set("token", token.RefreshToken)
+			data.Set("token_type_hint", "refresh_token")
+		} else {
+			data.Set("token", token.AccessToken)
+			data.Set("token_type_hint", "access_token")
+		}
+		if config.ClientSecret == "" {
+			data.Set("client_id", config.ClientID)
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", revocationURL, strings.NewReader(data.Encode()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if config.ClientSecret != "" {
+			req.SetBasicAuth(url.QueryEscape(config.ClientID), url.QueryEscape(config.ClientSecret))
+		}
+		resp, err := contextClient(ctx).Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			var body struct {
+				Error string `json:"error"`
+			}
+			b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+			if json.Unmarshal(b, &body) == nil && body.Error != "" {
+				return fmt.Errorf("oauth2: Token revocation failed: %s", body.Error)
+			}
+			return fmt.Errorf("oauth2: Token revocation failed with status %d", resp.StatusCode)
+		}
+		return nil
+	}
+	return RevokerFunc(fn)
+}
+
+// LogoutHandler revokes the Token from the ctx with the Revoker and expires
+// the cookie named by the CookieConfig, as well as cookies derived from it
+// (e.g. PKCE, nonce, return to, or per-flow cookies). If the ctx has no
+// Token (e.g. it was not loaded), revocation is skipped. If revocation
+// succeeds, handling delegates to the success handler, otherwise to the
+// failure handler. Cookies are expired either way.
+//
+// LogoutHandler only accepts POST requests, and responds to other methods
+// with 405 Method Not Allowed, so a cross-site link or image cannot revoke a
+// user's grant (e.g. a GitHub OAuth App grant). Apps whose session cookies
+// are sent with cross-site POSTs (SameSite None) must also mount it behind
+// CSRF protection.
+//
+// LogoutHandler does not end the app's own session, which the success
+// handler should clear.
+func LogoutHandler(revoker Revoker, config gologin.CookieConfig, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ctx := req.Context()
+		expireCookies(w, req, config)
+		if token, err := TokenFromContext(ctx); err == nil && revoker != nil {
+			if err := revoker.Revoke(ctx, token); err != nil {
+				ctx = gologin.WithError(ctx, err)
+				failure.ServeHTTP(w, req.WithContext(ctx))
+				return
+			}
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// expireCookies expires request cookies named by the CookieConfig or derived
+// from it.
+func expireCookies(w http.ResponseWriter, req *http.Request, config gologin.CookieConfig) {
+	for _, cookie := range req.Cookies() {
+		if cookie.Name == config.Name || strings.HasPrefix(cookie.Name, config.Name+"-") {
+			expired := config
+			expired.Name = cookie.Name
+			expired.MaxAge = -1
+			http.SetCookie(w, internal.NewCookie(expired, ""))
+		}
+	}
+}
+
+// contextClient returns the http.Client in the ctx under oauth2.HTTPClient,
+// like golang.org/x/oauth2, or the http.DefaultClient.
+func contextClient(ctx context.Context) *http.Client {
+	if client, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok {
+		return client
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,164 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestNewRevoker(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "POST", req.Method)
+		clientID, clientSecret, ok := req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "client_id", clientID)
+		assert.Equal(t, "client_secret", clientSecret)
+		assert.Equal(t, "refresh_token_val", req.PostFormValue("token"))
+		assert.Equal(t, "refresh_token", req.PostFormValue("token_type_hint"))
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+	}
+
+	// NewRevoker with a refresh token, assert that:
+	// - the refresh token is revoked with client Basic auth
+	revoker := NewRevoker(config, server.URL)
+	err := revoker.Revoke(context.Background(), &oauth2.Token{AccessToken: "access_token_val", RefreshToken: "refresh_token_val"})
+	assert.Nil(t, err)
+}
+
+func TestNewRevoker_PublicClient(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _, ok := req.BasicAuth()
+		assert.False(t, ok)
+		assert.Equal(t, "client_id", req.PostFormValue("client_id"))
+		assert.Equal(t, "access_token_val", req.PostFormValue("token"))
+		assert.Equal(t, "access_token", req.PostFormValue("token_type_hint"))
+	})
+	defer server.Close()
+
+	// NewRevoker for a client without a secret, assert that:
+	// - the access token is revoked with the client_id
+	revoker := NewRevoker(&oauth2.Config{ClientID: "client_id"}, server.URL)
+	err := revoker.Revoke(context.Background(), &oauth2.Token{AccessToken: "access_token_val"})
+	assert.Nil(t, err)
+}
+
+func TestNewRevoker_Error(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(contentType, jsonContentType)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"unsupported_token_type"}`))
+	})
+	defer server.Close()
+
+	revoker := NewRevoker(&oauth2.Config{ClientID: "client_id"}, server.URL)
+	err := revoker.Revoke(context.Background(), &oauth2.Token{AccessToken: "access_token_val"})
+	if assert.Error(t, err) {
+		assert.Equal(t, "oauth2: Token revocation failed: unsupported_token_type", err.Error())
+	}
+}
+
+func TestLogoutHandler(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	var revoked *oauth2.Token
+	revoker := RevokerFunc(func(ctx context.Context, token *oauth2.Token) error {
+		revoked = token
+		return nil
+	})
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// LogoutHandler with a Token in the ctx, assert that:
+	// - the Token is revoked
+	// - gologin cookies are expired, other cookies are not
+	// - success handler is called
+	handler := LogoutHandler(revoker, config, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "gologin-temporary-cookie", Value: "state"})
+	req.AddCookie(&http.Cookie{Name: "gologin-temporary-cookie-pkce", Value: "verifier"})
+	req.AddCookie(&http.Cookie{Name: "session", Value: "session"})
+	token := &oauth2.Token{AccessToken: "access_token_val"}
+	handler.ServeHTTP(w, req.WithContext(WithToken(req.Context(), token)))
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.Equal(t, token, revoked)
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 2) {
+		assert.Equal(t, "gologin-temporary-cookie", cookies[0].Name)
+		assert.Equal(t, -1, cookies[0].MaxAge)
+		assert.Equal(t, "gologin-temporary-cookie-pkce", cookies[1].Name)
+		assert.Equal(t, -1, cookies[1].MaxAge)
+	}
+}
+
+func TestLogoutHandler_RevokeError(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	revokeErr := errors.New("revoke error")
+	revoker := RevokerFunc(func(ctx context.Context, token *oauth2.Token) error {
+		return revokeErr
+	})
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, revokeErr, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// LogoutHandler with a failing Revoker, assert that:
+	// - cookies are still expired
+	// - failure handler is called
+	handler := LogoutHandler(revoker, config, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "gologin-temporary-cookie", Value: "state"})
+	handler.ServeHTTP(w, req.WithContext(WithToken(req.Context(), &oauth2.Token{})))
+	assert.Equal(t, "failure handler called", w.Body.String())
+	assert.Len(t, w.Result().Cookies(), 1)
+}
+
+func TestLogoutHandler_NoToken(t *testing.T) {
+	revoker := RevokerFunc(func(ctx context.Context, token *oauth2.Token) error {
+		assert.Fail(t, "unexpected revoke")
+		return nil
+	})
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// LogoutHandler without a Token in the ctx, assert that:
+	// - revocation is skipped
+	handler := LogoutHandler(revoker, gologin.DebugOnlyCookieConfig, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/logout", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestLogoutHandler_MethodNotAllowed(t *testing.T) {
+	revoker := RevokerFunc(func(ctx context.Context, token *oauth2.Token) error {
+		assert.Fail(t, "unexpected revoke")
+		return nil
+	})
+
+	// LogoutHandler with a GET request (e.g. a cross-site image), assert that:
+	// - the Token is not revoked and cookies are not expired
+	// - 405 Method Not Allowed is returned
+	handler := LogoutHandler(revoker, gologin.DebugOnlyCookieConfig, testutils.AssertSuccessNotCalled(t), testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "gologin-temporary-cookie", Value: "state"})
+	handler.ServeHTTP(w, req.WithContext(WithToken(req.Context(), &oauth2.Token{AccessToken: "access_token_val"})))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "POST", w.Header().Get("Allow"))
+	assert.Empty(t, w.Result().Cookies())
+}
@@ -0,0 +1,104 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dghubble/gologin/v2"
+	"golang.org/x/oauth2"
+)
+
+// loopbackCookieConfig configures the temporary cookies of loopback logins,
+// which are served over http on 127.0.0.1.
+var loopbackCookieConfig = gologin.CookieConfig{
+	Name:     "gologin-loopback",
+	Path:     "/",
+	MaxAge:   600,
+	HTTPOnly: true,
+	Secure:   false,
+	SameSite: http.SameSiteLaxMode,
+}
+
+// CallbackFunc returns a provider's callback handler for the Config (e.g.
+// github.CallbackHandler).
+type CallbackFunc func(config *oauth2.Config, success, failure http.Handler) http.Handler
+
+// LoopbackLogin logs in a user of a native or CLI app with a loopback
+// redirect (RFC 8252). It serves an ephemeral server on 127.0.0.1 with a
+// random port, sets the Config RedirectURL to its "/callback" route (or the
+// path of the existing RedirectURL), and calls open with the server's login
+// URL, which should be opened in (or printed for) the user's browser. Login
+// and callback requests are handled with StateHandler, PKCEHandler,
+// LoginHandler, and the handler returned by callback, so provider callback
+// handlers (e.g. github.CallbackHandler) may be used as is.
+//
+// LoopbackLogin returns the ctx the success handler received (e.g. with the
+// Token and provider User) or the failure handler's error, and then shuts
+// the server down. Cancel the ctx to stop waiting.
+func LoopbackLogin(ctx context.Context, config *oauth2.Config, callback CallbackFunc, open func(loginURL string) error) (context.Context, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	baseURL := "http://" + listener.Addr().String()
+	callbackPath := "/callback"
+	if redirectURL, err := url.Parse(config.RedirectURL); err == nil && redirectURL.Path != "" && redirectURL.Path != "/" {
+		callbackPath = redirectURL.Path
+	}
+	loopbackConfig := *config
+	loopbackConfig.RedirectURL = baseURL + callbackPath
+
+	type result struct {
+		ctx context.Context
+		err error
+	}
+	results := make(chan result, 1)
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "Login complete. You may close this window.\n")
+		select {
+		case results <- result{ctx: context.WithoutCancel(req.Context())}:
+		default:
+		}
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		http.Error(w, fmt.Sprintf("Login failed: %v", err), http.StatusBadRequest)
+		select {
+		case results <- result{err: err}:
+		default:
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/login", StateHandler(loopbackCookieConfig, PKCEHandler(loopbackCookieConfig, LoginHandler(&loopbackConfig, http.HandlerFunc(failure)))))
+	mux.Handle(callbackPath, StateHandler(loopbackCookieConfig, PKCEHandler(loopbackCookieConfig, callback(&loopbackConfig, http.HandlerFunc(success), http.HandlerFunc(failure)))))
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		// handlers see the caller's ctx values (e.g. oauth2.HTTPClient)
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+	go server.Serve(listener)
+	defer func() {
+		// let the browser receive the final response before shutting down
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := open(baseURL + "/login"); err != nil {
+		return nil, err
+	}
+	select {
+	case r := <-results:
+		return r.ctx, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,94 @@
+package oauth2
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// newLoopbackProvider returns a provider server which immediately redirects
+// authorization requests back with a code and exchanges codes for a Token.
+// The caller must close the server.
+func newLoopbackProvider(t *testing.T) (*oauth2.Config, func()) {
+	var challenge string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, req *http.Request) {
+		params := req.URL.Query()
+		assert.True(t, strings.HasPrefix(params.Get("redirect_uri"), "http://127.0.0.1:"))
+		assert.Equal(t, "S256", params.Get("code_challenge_method"))
+		challenge = params.Get("code_challenge")
+		redirect, _ := url.Parse(params.Get("redirect_uri"))
+		redirect.RawQuery = url.Values{"code": {"any_code"}, "state": {params.Get("state")}}.Encode()
+		http.Redirect(w, req, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, challenge, oauth2.S256ChallengeFromVerifier(req.PostFormValue("code_verifier")))
+		w.Header().Set(contentType, jsonContentType)
+		w.Write([]byte(`{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`))
+	})
+	server := NewTestServerFunc(mux.ServeHTTP)
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  server.URL + "/authorize",
+			TokenURL: server.URL + "/token",
+		},
+	}
+	return config, server.Close
+}
+
+// browser returns an open func which follows redirects like a browser.
+func browser(t *testing.T) func(string) error {
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	return func(loginURL string) error {
+		go func() {
+			resp, err := client.Get(loginURL)
+			if assert.Nil(t, err) {
+				resp.Body.Close()
+			}
+		}()
+		return nil
+	}
+}
+
+func TestLoopbackLogin(t *testing.T) {
+	config, closeServer := newLoopbackProvider(t)
+	defer closeServer()
+
+	// LoopbackLogin, assert that:
+	// - the login URL is served on 127.0.0.1
+	// - the callback chain (with state and PKCE) obtains a Token
+	// - the success handler's ctx is returned
+	ctx, err := LoopbackLogin(context.Background(), config, CallbackHandler, browser(t))
+	if assert.Nil(t, err) {
+		token, err := TokenFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "2YotnFZFEjr1zCsicMWpAA", token.AccessToken)
+	}
+	// the caller's Config is not modified
+	assert.Equal(t, "", config.RedirectURL)
+}
+
+func TestLoopbackLogin_Cancel(t *testing.T) {
+	config, closeServer := newLoopbackProvider(t)
+	defer closeServer()
+
+	// LoopbackLogin where the user never logs in, assert that:
+	// - the ctx error is returned once the ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	open := func(loginURL string) error {
+		assert.True(t, strings.HasPrefix(loginURL, "http://127.0.0.1:"))
+		return nil
+	}
+	_, err := LoopbackLogin(ctx, config, CallbackHandler, open)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
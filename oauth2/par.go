@@ -0,0 +1,99 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// clientAuthBase is the ClientAuth added by WithClientAuth and the
+// http.Client it wrapped.
+type clientAuthBase struct {
+	auth   ClientAuth
+	client *http.Client
+}
+
+// PushedAuthHandler adds the provider's Pushed Authorization Request (RFC
+// 9126) endpoint URL to the ctx, so a downstream LoginHandler pushes the
+// authorization request parameters to it and redirects with only the
+// client_id and request_uri.
+func PushedAuthHandler(pushedAuthURL string, success http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := WithPushedAuthURL(req.Context(), pushedAuthURL)
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// pushAuthRequest POSTs the query parameters of the authURL to the pushed
+// authorization request endpoint and returns the AuthURL redirect with the
+// request_uri it issued. The client authenticates as it does to the token
+// endpoint: with a ClientAuth added by WithClientAuth, or its client secret.
+func pushAuthRequest(ctx context.Context, config *oauth2.Config, pushedAuthURL, authURL string) (string, error) {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		return "", err
+	}
+	data := parsed.Query()
+	client := contextClient(ctx)
+	base, hasClientAuth := ctx.Value(clientAuthKey).(clientAuthBase)
+	basicAuth := !hasClientAuth && config.ClientSecret != "" && config.Endpoint.AuthStyle != oauth2.AuthStyleInParams
+	if hasClientAuth {
+		transport := base.client.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		authClient := *base.client
+		authClient.Transport = base.auth.Transport(pushedAuthURL, transport)
+		client = &authClient
+	} else if config.ClientSecret != "" && config.Endpoint.AuthStyle == oauth2.AuthStyleInParams {
+		data.Set("client_secret", config.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", pushedAuthURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if basicAuth {
+		req.SetBasicAuth(url.QueryEscape(config.ClientID), url.QueryEscape(config.ClientSecret))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		RequestURI string `json:"request_uri"`
+		Error      string `json:"error"`
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err := json.Unmarshal(b, &body); err != nil && resp.StatusCode == http.StatusCreated {
+		return "", fmt.Errorf("oauth2: Invalid pushed authorization response: %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		if body.Error != "" {
+			return "", fmt.Errorf("oauth2: Pushed authorization request failed: %s", body.Error)
+		}
+		return "", fmt.Errorf("oauth2: Pushed authorization request failed with status %d", resp.StatusCode)
+	}
+	if body.RequestURI == "" {
+		return "", fmt.Errorf("oauth2: Pushed authorization response missing request_uri")
+	}
+	v := url.Values{
+		"client_id":   {config.ClientID},
+		"request_uri": {body.RequestURI},
+	}
+	redirect := config.Endpoint.AuthURL
+	if strings.Contains(redirect, "?") {
+		redirect += "&"
+	} else {
+		redirect += "?"
+	}
+	return redirect + v.Encode(), nil
+}
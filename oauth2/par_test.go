@@ -0,0 +1,121 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+const requestURI = "urn:ietf:params:oauth:request_uri:6esc_11ACC5bwc014ltc14eY22c"
+
+func TestLoginHandler_PushedAuth(t *testing.T) {
+	verifier := oauth2.GenerateVerifier()
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "POST", req.Method)
+		clientID, clientSecret, ok := req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "client_id", clientID)
+		assert.Equal(t, "client_secret", clientSecret)
+		assert.Equal(t, "state_val", req.PostFormValue("state"))
+		assert.Equal(t, "redirect_url", req.PostFormValue("redirect_uri"))
+		assert.Equal(t, "code", req.PostFormValue("response_type"))
+		assert.Equal(t, oauth2.S256ChallengeFromVerifier(verifier), req.PostFormValue("code_challenge"))
+		w.Header().Set(contentType, jsonContentType)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"request_uri":%q,"expires_in":60}`, requestURI)
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RedirectURL:  "redirect_url",
+		Endpoint: oauth2.Endpoint{
+			AuthURL: "https://api.example.com/authorize",
+		},
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// PushedAuthHandler and LoginHandler, assert that:
+	// - the parameters are pushed with client authentication
+	// - redirect url includes only the client_id and request_uri
+	handler := PushedAuthHandler(server.URL+"/par", LoginHandler(config, failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := WithState(context.Background(), "state_val")
+	ctx = WithVerifier(ctx, verifier)
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://api.example.com/authorize?client_id=client_id&request_uri=urn%3Aietf%3Aparams%3Aoauth%3Arequest_uri%3A6esc_11ACC5bwc014ltc14eY22c", w.Result().Header.Get("Location"))
+}
+
+func TestLoginHandler_PushedAuthClientAuth(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "urn:ietf:params:oauth:client-assertion-type:jwt-bearer", req.PostFormValue("client_assertion_type"))
+		assert.NotEmpty(t, req.PostFormValue("client_assertion"))
+		assert.Equal(t, "state_val", req.PostFormValue("state"))
+		w.Header().Set(contentType, jsonContentType)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"request_uri":%q,"expires_in":60}`, requestURI)
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://api.example.com/authorize?audience=api",
+			TokenURL: server.URL + "/token",
+		},
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// ClientAuthHandler, PushedAuthHandler, and LoginHandler, assert that:
+	// - the pushed authorization request is authenticated with the ClientAuth
+	// - redirect url keeps the AuthURL query
+	auth := PrivateKeyJWT("client_id", key, "key-1")
+	handler := ClientAuthHandler(config, auth, PushedAuthHandler(server.URL+"/par", LoginHandler(config, failure)))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req.WithContext(WithState(context.Background(), "state_val")))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://api.example.com/authorize?audience=api&client_id=client_id&request_uri=urn%3Aietf%3Aparams%3Aoauth%3Arequest_uri%3A6esc_11ACC5bwc014ltc14eY22c", w.Result().Header.Get("Location"))
+}
+
+func TestLoginHandler_PushedAuthError(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(contentType, jsonContentType)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error":"invalid_request","error_description":"invalid redirect_uri"}`)
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		ClientID: "client_id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL: "https://api.example.com/authorize",
+		},
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		if assert.Error(t, err) {
+			assert.Equal(t, "oauth2: Pushed authorization request failed: invalid_request", err.Error())
+		}
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// PushedAuthHandler and LoginHandler, assert that:
+	// - failure handler is called with the error response
+	handler := PushedAuthHandler(server.URL+"/par", LoginHandler(config, http.HandlerFunc(failure)))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req.WithContext(WithState(context.Background(), "state_val")))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
@@ -0,0 +1,25 @@
+package oauth2
+
+import (
+	"net/http"
+
+	"github.com/dghubble/gologin/v2"
+)
+
+// ProviderOptions configure the handlers which provider packages' NewProvider
+// functions chain before their LoginHandler and CallbackHandler. A nil
+// *ProviderOptions uses the defaults.
+type ProviderOptions struct {
+	// PKCE enables PKCE (RFC 7636) code challenges by chaining PKCEHandler,
+	// whose verifier cookie is named after the state CookieConfig.
+	PKCE bool
+}
+
+// ProviderHandler chains StateHandler, and any handlers enabled by the
+// options, before a provider's login or callback handler.
+func ProviderHandler(stateConfig gologin.CookieConfig, options *ProviderOptions, success http.Handler) http.Handler {
+	if options != nil && options.PKCE {
+		success = PKCEHandler(stateConfig, success)
+	}
+	return StateHandler(stateConfig, success)
+}
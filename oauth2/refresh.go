@@ -0,0 +1,94 @@
+package oauth2
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/dghubble/gologin/v2"
+	"golang.org/x/oauth2"
+)
+
+// TokenStore persists users' OAuth2 Tokens after login (e.g. keyed by the
+// session of the request) so they may be reused and refreshed.
+type TokenStore interface {
+	// LoadToken returns the Token of the user making the request.
+	LoadToken(req *http.Request) (*oauth2.Token, error)
+	// SaveToken stores the (refreshed) Token of the user making the request.
+	SaveToken(w http.ResponseWriter, req *http.Request, token *oauth2.Token) error
+}
+
+// RefreshHandler loads the user's Token from the TokenStore, refreshes it
+// using the Config if it has expired, and saves a refreshed Token back to
+// the store. The Token and an http.Client which authorizes requests with it
+// are added to the ctx and the success handler is called. If the Token
+// cannot be loaded, refreshed, or saved, the failure handler is called.
+//
+// The http.Client from ClientFromContext refreshes the Token again if needed
+// and saves it with the store, but should be used before the response is
+// written, since stores may issue cookies.
+func RefreshHandler(config *oauth2.Config, store TokenStore, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := store.LoadToken(req)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		save := func(token *oauth2.Token) error {
+			return store.SaveToken(w, req, token)
+		}
+		src := NotifyTokenSource(token, config.TokenSource(ctx, token), save)
+		// refresh an expired Token now, so failures are handled here
+		token, err = src.Token()
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithToken(ctx, token)
+		ctx = WithClient(ctx, oauth2.NewClient(ctx, src))
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// NotifyTokenSource returns a TokenSource which returns Tokens from src and
+// calls notify whenever src returns a Token with a different access token
+// than the last one (i.e. it was refreshed), so it may be persisted. The
+// initial Token is the Token last known to the caller, which may be nil.
+func NotifyTokenSource(initial *oauth2.Token, src oauth2.TokenSource, notify func(*oauth2.Token) error) oauth2.TokenSource {
+	return &notifyTokenSource{
+		src:    src,
+		last:   initial,
+		notify: notify,
+	}
+}
+
+// notifyTokenSource calls notify with refreshed Tokens.
+type notifyTokenSource struct {
+	src    oauth2.TokenSource
+	notify func(*oauth2.Token) error
+
+	mu   sync.Mutex
+	last *oauth2.Token
+}
+
+func (s *notifyTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	if s.last == nil || token.AccessToken != s.last.AccessToken {
+		if err := s.notify(token); err != nil {
+			return nil, err
+		}
+		s.last = token
+	}
+	return token, nil
+}
@@ -0,0 +1,139 @@
+package oauth2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// memoryTokenStore is a TokenStore of a single user's Token.
+type memoryTokenStore struct {
+	token *oauth2.Token
+	saved int
+}
+
+func (s *memoryTokenStore) LoadToken(req *http.Request) (*oauth2.Token, error) {
+	if s.token == nil {
+		return nil, errors.New("no token")
+	}
+	return s.token, nil
+}
+
+func (s *memoryTokenStore) SaveToken(w http.ResponseWriter, req *http.Request, token *oauth2.Token) error {
+	s.token = token
+	s.saved++
+	return nil
+}
+
+func TestRefreshHandler(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"refreshed-token","token_type":"Bearer","refresh_token":"refresh-token","expires_in":3600}`)
+	defer server.Close()
+	api := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer refreshed-token", req.Header.Get("Authorization"))
+	})
+	defer api.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	store := &memoryTokenStore{token: &oauth2.Token{
+		AccessToken:  "expired-token",
+		RefreshToken: "refresh-token",
+		Expiry:       time.Now().Add(-time.Hour),
+	}}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		token, err := TokenFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "refreshed-token", token.AccessToken)
+		client, err := ClientFromContext(req.Context())
+		assert.Nil(t, err)
+		_, err = client.Get(api.URL)
+		assert.Nil(t, err)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// RefreshHandler with an expired Token, assert that:
+	// - the Token is refreshed and saved once
+	// - the refreshed Token and an authorized Client are added to the ctx
+	handler := RefreshHandler(config, store, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.Equal(t, 1, store.saved)
+	assert.Equal(t, "refreshed-token", store.token.AccessToken)
+}
+
+func TestRefreshHandler_ValidToken(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Fail(t, "unexpected token refresh")
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	store := &memoryTokenStore{token: &oauth2.Token{
+		AccessToken: "valid-token",
+		Expiry:      time.Now().Add(time.Hour),
+	}}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		token, err := TokenFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "valid-token", token.AccessToken)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// RefreshHandler with a valid Token, assert that:
+	// - the Token is not refreshed or saved
+	handler := RefreshHandler(config, store, http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	assert.Equal(t, 0, store.saved)
+}
+
+func TestRefreshHandler_Errors(t *testing.T) {
+	server := NewTestServerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(contentType, jsonContentType)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+	})
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.NotNil(t, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// RefreshHandler without a stored Token or with a revoked refresh token,
+	// assert that:
+	// - the failure handler is called
+	stores := []*memoryTokenStore{
+		{},
+		{token: &oauth2.Token{AccessToken: "expired-token", RefreshToken: "revoked", Expiry: time.Now().Add(-time.Hour)}},
+	}
+	for _, store := range stores {
+		handler := RefreshHandler(config, store, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, "failure handler called", w.Body.String())
+		assert.Equal(t, 0, store.saved)
+	}
+}
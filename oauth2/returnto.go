@@ -0,0 +1,94 @@
+package oauth2
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/dghubble/gologin/v2/internal"
+)
+
+// ReturnToHandler preserves the URL a user should return to after login. On
+// login requests with a "next" or "return_to" parameter, the URL is checked,
+// added to the ctx, and kept in a (short-lived) cookie bound to the ctx state.
+// On callback requests, the cookie URL is added to the ctx if it was bound to
+// the same state. The cookie name is the CookieConfig name suffixed with
+// "-return-to", so the same CookieConfig given to StateHandler may be used.
+//
+// To prevent open redirects, only relative paths (e.g. "/settings") and
+// http(s) URLs whose host is one of the allowedHosts are accepted. Other
+// URLs are ignored. Chain ReturnToHandler after a StateHandler and use
+// ReturnToFromContext in the success handler.
+func ReturnToHandler(config gologin.CookieConfig, allowedHosts []string, success http.Handler) http.Handler {
+	internal.MustValidConfig(config)
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		config := derivedConfig(ctx, config, "return-to")
+		state, err := StateFromContext(ctx)
+		if err != nil {
+			success.ServeHTTP(w, req)
+			return
+		}
+		returnTo := req.FormValue("next")
+		if returnTo == "" {
+			returnTo = req.FormValue("return_to")
+		}
+		if returnTo != "" && validReturnTo(returnTo, allowedHosts) {
+			// login phase, bind the URL to the state
+			val := state + "." + base64.RawURLEncoding.EncodeToString([]byte(returnTo))
+			http.SetCookie(w, internal.NewCookie(config, val))
+			ctx = WithReturnTo(ctx, returnTo)
+		} else if value, err := internal.ReadCookie(req, config); err == nil {
+			// callback phase, read the URL bound to the state
+			if returnTo, ok := parseReturnTo(value, state); ok && validReturnTo(returnTo, allowedHosts) {
+				ctx = WithReturnTo(ctx, returnTo)
+			}
+			ctx = withTempCookie(ctx, config)
+		}
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// parseReturnTo returns the URL from a return to cookie value, if it is
+// bound to the given state.
+func parseReturnTo(value, state string) (string, bool) {
+	// encoded URLs never contain ".", but states may (e.g. signed states)
+	i := strings.LastIndex(value, ".")
+	if i < 0 || value[:i] != state {
+		return "", false
+	}
+	returnTo, err := base64.RawURLEncoding.DecodeString(value[i+1:])
+	if err != nil {
+		return "", false
+	}
+	return string(returnTo), true
+}
+
+// validReturnTo returns true if the URL is a relative path or an http(s) URL
+// whose host is allowed.
+func validReturnTo(returnTo string, allowedHosts []string) bool {
+	// browsers treat backslashes as slashes (e.g. "/\evil.com")
+	if strings.ContainsAny(returnTo, "\\\x00\r\n\t") {
+		return false
+	}
+	u, err := url.Parse(returnTo)
+	if err != nil || u.User != nil {
+		return false
+	}
+	if u.Scheme == "" && u.Host == "" {
+		// relative paths only, not scheme-relative "//evil.com"
+		return strings.HasPrefix(returnTo, "/") && !strings.HasPrefix(returnTo, "//")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	for _, host := range allowedHosts {
+		if strings.EqualFold(u.Host, host) {
+			return true
+		}
+	}
+	return false
+}
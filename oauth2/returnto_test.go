@@ -0,0 +1,125 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReturnToHandler(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	allowedHosts := []string{"app.example.com"}
+	loginSuccess := func(w http.ResponseWriter, req *http.Request) {
+		returnTo, err := ReturnToFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "/settings?tab=profile", returnTo)
+	}
+	callbackSuccess := func(w http.ResponseWriter, req *http.Request) {
+		returnTo, err := ReturnToFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "/settings?tab=profile", returnTo)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// ReturnToHandler login phase, assert that:
+	// - the return to URL is added to the ctx
+	// - a cookie binding the URL to the state is issued
+	handler := ReturnToHandler(config, allowedHosts, http.HandlerFunc(loginSuccess))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/login?next=%2Fsettings%3Ftab%3Dprofile", nil)
+	ctx := WithState(context.Background(), "d4e5f6")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	cookies := w.Result().Cookies()
+	if !assert.Len(t, cookies, 1) {
+		return
+	}
+	assert.Equal(t, "gologin-temporary-cookie-return-to", cookies[0].Name)
+
+	// ReturnToHandler callback phase, assert that:
+	// - the return to URL bound to the state is added to the ctx
+	handler = ReturnToHandler(config, allowedHosts, http.HandlerFunc(callbackSuccess))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/callback?code=any_code&state=d4e5f6", nil)
+	req.AddCookie(cookies[0])
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestReturnToHandler_StateMismatch(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	success := func(w http.ResponseWriter, req *http.Request) {
+		_, err := ReturnToFromContext(req.Context())
+		assert.Error(t, err)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// ReturnToHandler called with a cookie bound to another state, assert that:
+	// - no return to URL is added to the ctx
+	handler := ReturnToHandler(config, nil, http.HandlerFunc(success))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/callback", nil)
+	req.AddCookie(&http.Cookie{Name: "gologin-temporary-cookie-return-to", Value: "other-state.L3NldHRpbmdz"})
+	ctx := WithState(context.Background(), "d4e5f6")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestReturnToHandler_OpenRedirect(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	success := func(w http.ResponseWriter, req *http.Request) {
+		_, err := ReturnToFromContext(req.Context())
+		assert.Error(t, err)
+	}
+
+	// ReturnToHandler called with a disallowed URL, assert that:
+	// - no return to URL is added to the ctx
+	// - no cookie is issued
+	handler := ReturnToHandler(config, []string{"app.example.com"}, http.HandlerFunc(success))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/login?return_to=https%3A%2F%2Fevil.example.com%2F", nil)
+	ctx := WithState(context.Background(), "d4e5f6")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Empty(t, w.Result().Cookies())
+}
+
+func TestValidReturnTo(t *testing.T) {
+	allowedHosts := []string{"app.example.com", "localhost:8080"}
+	cases := []struct {
+		returnTo string
+		expected bool
+	}{
+		{"/", true},
+		{"/settings?tab=profile#top", true},
+		{"https://app.example.com/settings", true},
+		{"http://localhost:8080/", true},
+		{"https://APP.example.com/", true},
+		{"settings", false},
+		{"//evil.example.com/", false},
+		{"/\\evil.example.com/", false},
+		{"https://evil.example.com/", false},
+		{"https://app.example.com.evil.example.com/", false},
+		{"https://user@app.example.com/", false},
+		{"javascript:alert(1)", false},
+		{"ftp://app.example.com/", false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, validReturnTo(c.returnTo, allowedHosts), c.returnTo)
+	}
+}
+
+func TestParseReturnTo(t *testing.T) {
+	// states containing "." (e.g. signed states) are bound
+	returnTo, ok := parseReturnTo("a.b.L3NldHRpbmdz", "a.b")
+	assert.True(t, ok)
+	assert.Equal(t, "/settings", returnTo)
+
+	_, ok = parseReturnTo("a.b.L3NldHRpbmdz", "a")
+	assert.False(t, ok)
+	_, ok = parseReturnTo("L3NldHRpbmdz", "")
+	assert.False(t, ok)
+}
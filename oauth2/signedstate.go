@@ -0,0 +1,188 @@
+package oauth2
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/dghubble/gologin/v2/internal"
+)
+
+// defaultStateTTL is the lifetime of signed states when no TTL is set.
+const defaultStateTTL = 10 * time.Minute
+
+// providerPayloadKey is the payload key recording the provider name (see
+// gologin.Registry) a signed state was issued for.
+const providerPayloadKey = "provider"
+
+// SignedStateConfig configures SignedStateHandler.
+type SignedStateConfig struct {
+	// Keys sign states with HMAC-SHA256. The first key signs new states and
+	// all keys are tried to verify, which allows keys to be rotated.
+	Keys [][]byte
+	// TTL is how long a state remains valid (default 10 minutes).
+	TTL time.Duration
+	// BindingCookie optionally binds states to the browser which started the
+	// login, via a random value in a cookie with this CookieConfig. Leave the
+	// Name empty to issue unbound states (e.g. for cookie-less webviews).
+	BindingCookie gologin.CookieConfig
+	// Payload optionally returns values to embed in the state on login
+	// requests (e.g. a return to URL). Payloads are signed, but not
+	// encrypted. The "provider" key is reserved for the provider name.
+	Payload func(req *http.Request) map[string]string
+}
+
+// signedState is the signed content of a state value.
+type signedState struct {
+	IssuedAt  int64             `json:"iat"`
+	ExpiresAt int64             `json:"exp"`
+	Nonce     string            `json:"n"`
+	Binding   string            `json:"b,omitempty"`
+	Payload   map[string]string `json:"p,omitempty"`
+}
+
+// SignedStateHandler issues self-contained states signed with an app key,
+// as an alternative to comparing state with a cookie. On login requests, a
+// state embedding its issued at time, expiry, a random nonce, and optional
+// payload is added to the ctx. On callback requests (with a "state"
+// parameter), CallbackHandler verifies the signature and expiry (and browser
+// binding, if configured) and adds the payload to the ctx. Expired states are
+// rejected with ErrStateExpired and otherwise invalid states with
+// ErrInvalidState.
+//
+// Use SignedStateHandler in place of StateHandler.
+func SignedStateHandler(config SignedStateConfig, success http.Handler) http.Handler {
+	if len(config.Keys) == 0 {
+		panic("oauth2: SignedStateHandler requires signing Keys")
+	}
+	internal.MustValidConfig(config.BindingCookie)
+	if config.TTL == 0 {
+		config.TTL = defaultStateTTL
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if state := req.FormValue("state"); state != "" {
+			// callback phase, CallbackHandler verifies the state
+			ctx = WithState(ctx, state)
+			ctx = withStateVerifier(ctx, func(req *http.Request, state string) (map[string]string, error) {
+				payload, err := verifyState(config, req, state, time.Now())
+				if err != nil {
+					return nil, err
+				}
+				// a state issued for another provider indicates a mix-up
+				if name, ok := payload[providerPayloadKey]; ok {
+					if err := checkProvider(withStateProvider(req.Context(), name)); err != nil {
+						return nil, err
+					}
+				}
+				return payload, nil
+			})
+			if config.BindingCookie.Name != "" {
+				ctx = withTempCookie(ctx, config.BindingCookie)
+			}
+			success.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// login phase, issue a signed state
+		var binding string
+		if config.BindingCookie.Name != "" {
+			var err error
+			if binding, err = internal.ReadCookie(req, config.BindingCookie); err != nil {
+				binding = randomState()
+				http.SetCookie(w, internal.NewCookie(config.BindingCookie, binding))
+			}
+		}
+		payload := map[string]string{}
+		if config.Payload != nil {
+			for k, v := range config.Payload(req) {
+				payload[k] = v
+			}
+		}
+		if name := providerName(ctx); name != "" {
+			payload[providerPayloadKey] = name
+		}
+		ctx = WithState(ctx, signState(config, binding, payload, time.Now()))
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// signState returns a state value signed with the first key.
+func signState(config SignedStateConfig, binding string, payload map[string]string, now time.Time) string {
+	content := signedState{
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(config.TTL).Unix(),
+		Nonce:     randomState(),
+		Payload:   payload,
+	}
+	if binding != "" {
+		content.Binding = bindingHash(binding)
+	}
+	data, _ := json.Marshal(content)
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(stateMAC(config.Keys[0], encoded))
+}
+
+// verifyState verifies a state value issued by signState and returns its
+// payload.
+func verifyState(config SignedStateConfig, req *http.Request, state string, now time.Time) (map[string]string, error) {
+	encoded, macEncoded, ok := strings.Cut(state, ".")
+	if !ok {
+		return nil, ErrInvalidState
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(macEncoded)
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+	var valid bool
+	for _, key := range config.Keys {
+		if hmac.Equal(mac, stateMAC(key, encoded)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, ErrInvalidState
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+	content := new(signedState)
+	if err := json.Unmarshal(data, content); err != nil {
+		return nil, ErrInvalidState
+	}
+	if now.After(time.Unix(content.ExpiresAt, 0)) {
+		return nil, ErrStateExpired
+	}
+	if config.BindingCookie.Name != "" {
+		binding, err := internal.ReadCookie(req, config.BindingCookie)
+		if err != nil || subtle.ConstantTimeCompare([]byte(bindingHash(binding)), []byte(content.Binding)) != 1 {
+			return nil, ErrInvalidState
+		}
+	}
+	if content.Payload == nil {
+		content.Payload = map[string]string{}
+	}
+	return content.Payload, nil
+}
+
+// stateMAC returns the HMAC-SHA256 of the encoded state content.
+func stateMAC(key []byte, encoded string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(encoded))
+	return h.Sum(nil)
+}
+
+// bindingHash returns the hash of a binding cookie value embedded in states,
+// so states do not reveal the cookie value.
+func bindingHash(binding string) string {
+	sum := sha256.Sum256([]byte(binding))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
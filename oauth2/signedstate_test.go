@@ -0,0 +1,123 @@
+package oauth2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func signedStateConfig() SignedStateConfig {
+	return SignedStateConfig{
+		Keys:          [][]byte{[]byte("state-signing-key")},
+		BindingCookie: gologin.DebugOnlyCookieConfig,
+		Payload: func(req *http.Request) map[string]string {
+			return map[string]string{"return_to": req.FormValue("next")}
+		},
+	}
+}
+
+func TestSignedStateHandler(t *testing.T) {
+	server := NewAccessTokenServer(t, `{"access_token":"2YotnFZFEjr1zCsicMWpAA","token_type":"example"}`)
+	defer server.Close()
+	config := &oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			TokenURL: server.URL,
+		},
+	}
+	stateConfig := signedStateConfig()
+
+	var state string
+	loginSuccess := func(w http.ResponseWriter, req *http.Request) {
+		var err error
+		state, err = StateFromContext(req.Context())
+		assert.Nil(t, err)
+	}
+	callbackSuccess := func(w http.ResponseWriter, req *http.Request) {
+		payload, err := StatePayloadFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"return_to": "/settings"}, payload)
+		fmt.Fprintf(w, "success handler called")
+	}
+
+	// SignedStateHandler login phase, assert that:
+	// - a signed state is added to the ctx
+	// - a binding cookie is issued
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/login?next=/settings", nil)
+	SignedStateHandler(stateConfig, http.HandlerFunc(loginSuccess)).ServeHTTP(w, req)
+	assert.NotEmpty(t, state)
+	cookies := w.Result().Cookies()
+	if !assert.Len(t, cookies, 1) {
+		return
+	}
+
+	// SignedStateHandler and CallbackHandler callback phase, assert that:
+	// - the signed state is verified
+	// - the payload is added to the ctx
+	// - the binding cookie is expired
+	handler := SignedStateHandler(stateConfig, CallbackHandler(config, http.HandlerFunc(callbackSuccess), testutils.AssertFailureNotCalled(t)))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/callback?code=any_code&state="+url.QueryEscape(state), nil)
+	req.AddCookie(cookies[0])
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+	if cookies := w.Result().Cookies(); assert.Len(t, cookies, 1) {
+		assert.Equal(t, -1, cookies[0].MaxAge)
+	}
+}
+
+func TestSignedStateHandler_Invalid(t *testing.T) {
+	config := &oauth2.Config{}
+	stateConfig := signedStateConfig()
+	stateConfig.TTL = time.Minute
+	binding := &http.Cookie{Name: stateConfig.BindingCookie.Name, Value: "binding"}
+	now := time.Now()
+
+	cases := []struct {
+		state  string
+		cookie *http.Cookie
+		err    error
+	}{
+		// valid signature, but expired
+		{signState(stateConfig, "binding", nil, now.Add(-time.Hour)), binding, ErrStateExpired},
+		// tampered
+		{signState(stateConfig, "binding", nil, now) + "x", binding, ErrInvalidState},
+		// signed with another key
+		{signState(SignedStateConfig{Keys: [][]byte{[]byte("other")}, TTL: time.Minute}, "binding", nil, now), binding, ErrInvalidState},
+		// missing binding cookie
+		{signState(stateConfig, "binding", nil, now), nil, ErrInvalidState},
+		// another browser's binding cookie
+		{signState(stateConfig, "other", nil, now), binding, ErrInvalidState},
+	}
+	for _, c := range cases {
+		failure := func(w http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, c.err, gologin.ErrorFromContext(req.Context()))
+			fmt.Fprintf(w, "failure handler called")
+		}
+		handler := SignedStateHandler(stateConfig, CallbackHandler(config, testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure)))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/callback?code=any_code&state="+url.QueryEscape(c.state), nil)
+		if c.cookie != nil {
+			req.AddCookie(c.cookie)
+		}
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, "failure handler called", w.Body.String())
+	}
+}
+
+func TestSignedStateHandler_KeyRotation(t *testing.T) {
+	old := SignedStateConfig{Keys: [][]byte{[]byte("old-key")}, TTL: time.Minute}
+	rotated := SignedStateConfig{Keys: [][]byte{[]byte("new-key"), []byte("old-key")}, TTL: time.Minute}
+	req, _ := http.NewRequest("GET", "/", nil)
+	payload, err := verifyState(rotated, req, signState(old, "", nil, time.Now()), time.Now())
+	assert.Nil(t, err)
+	assert.Empty(t, payload)
+}
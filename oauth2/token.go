@@ -0,0 +1,57 @@
+package oauth2
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dghubble/gologin/v2"
+	"golang.org/x/oauth2"
+)
+
+// accessTokenField is the RFC 6750 form-encoded body parameter.
+const accessTokenField = "access_token"
+
+// ErrMissingToken is returned when a request has no bearer access token.
+var ErrMissingToken = errors.New("oauth2: Missing bearer access token")
+
+// TokenHandler receives an OAuth2 access token obtained by a native or
+// single-page client, from a "Bearer" Authorization header or an
+// "access_token" POST form field (RFC 6750), and adds it to the ctx as a
+// Token. If there is no token, the failure handler is called.
+//
+// The token is NOT verified. Use a provider TokenHandler (e.g.
+// google.TokenHandler), which verifies the token was issued to the client
+// and gets the corresponding user.
+func TokenHandler(success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		accessToken := bearerToken(req)
+		if accessToken == "" {
+			ctx = gologin.WithError(ctx, ErrMissingToken)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithToken(ctx, &oauth2.Token{AccessToken: accessToken, TokenType: "Bearer"})
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// bearerToken returns the bearer access token of the request, if any. Query
+// parameters are not read, since URLs are commonly logged.
+func bearerToken(req *http.Request) string {
+	if scheme, token, found := strings.Cut(req.Header.Get("Authorization"), " "); found {
+		if strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	if req.Method == "POST" {
+		return req.PostFormValue(accessTokenField)
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package oauth2
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenHandler(t *testing.T) {
+	success := func(w http.ResponseWriter, req *http.Request) {
+		token, err := TokenFromContext(req.Context())
+		assert.Nil(t, err)
+		assert.Equal(t, "access_token_val", token.AccessToken)
+		assert.Equal(t, "Bearer", token.Type())
+		fmt.Fprintf(w, "success handler called")
+	}
+	handler := TokenHandler(http.HandlerFunc(success), testutils.AssertFailureNotCalled(t))
+
+	// TokenHandler with a Bearer Authorization header, assert that:
+	// - the Token is added to the ctx of the success handler
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "bearer access_token_val")
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+
+	// TokenHandler with an access_token POST form field
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/", strings.NewReader(url.Values{"access_token": {"access_token_val"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestTokenHandler_MissingToken(t *testing.T) {
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrMissingToken, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+	handler := TokenHandler(testutils.AssertSuccessNotCalled(t), http.HandlerFunc(failure))
+
+	// TokenHandler without a Bearer token, assert that:
+	// - query parameters and other Authorization schemes are ignored
+	// - failure handler is called
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/?access_token=access_token_val", nil),
+		httptest.NewRequest("POST", "/", nil),
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, "failure handler called", w.Body.String())
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.SetBasicAuth("user", "pass")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
@@ -0,0 +1,42 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+)
+
+// unexported key type prevents collisions
+type key int
+
+const (
+	claimsKey key = iota
+	idTokenKey
+)
+
+// WithClaims returns a copy of ctx that stores the ID token Claims.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+// ClaimsFromContext returns the ID token Claims from the ctx.
+func ClaimsFromContext(ctx context.Context) (*Claims, error) {
+	claims, ok := ctx.Value(claimsKey).(*Claims)
+	if !ok {
+		return nil, fmt.Errorf("oidc: Context missing ID token Claims")
+	}
+	return claims, nil
+}
+
+// WithIDToken returns a copy of ctx that stores the raw ID token.
+func WithIDToken(ctx context.Context, rawIDToken string) context.Context {
+	return context.WithValue(ctx, idTokenKey, rawIDToken)
+}
+
+// IDTokenFromContext returns the raw ID token from the ctx.
+func IDTokenFromContext(ctx context.Context) (string, error) {
+	rawIDToken, ok := ctx.Value(idTokenKey).(string)
+	if !ok {
+		return "", fmt.Errorf("oidc: Context missing ID token")
+	}
+	return rawIDToken, nil
+}
@@ -0,0 +1,24 @@
+package oidc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextClaims(t *testing.T) {
+	expectedClaims := &Claims{Subject: "42", Name: "OIDC User"}
+	ctx := WithClaims(context.Background(), expectedClaims)
+	claims, err := ClaimsFromContext(ctx)
+	assert.Equal(t, expectedClaims, claims)
+	assert.Nil(t, err)
+}
+
+func TestContextClaims_Error(t *testing.T) {
+	claims, err := ClaimsFromContext(context.Background())
+	assert.Nil(t, claims)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "oidc: Context missing ID token Claims", err.Error())
+	}
+}
@@ -0,0 +1,3 @@
+// Package oidc provides OpenID Connect login and callback handlers for any
+// compliant issuer, verifying ID tokens against the issuer's published keys.
+package oidc
@@ -0,0 +1,90 @@
+package oidc
+
+import (
+	"net/http"
+
+	"github.com/dghubble/gologin/v2"
+	oauth2Login "github.com/dghubble/gologin/v2/oauth2"
+	"golang.org/x/oauth2"
+)
+
+// StateHandler checks for a state cookie. If found, the state value is read
+// and added to the ctx. Otherwise, a non-guessable value is added to the ctx
+// and to a (short-lived) state cookie issued to the requester.
+//
+// Implements OAuth 2 RFC 6749 10.12 CSRF Protection. If you wish to issue
+// state params differently, write a http.Handler which sets the ctx state,
+// using oauth2 WithState(ctx, state) since it is required by LoginHandler
+// and CallbackHandler.
+func StateHandler(config gologin.CookieConfig, success http.Handler) http.Handler {
+	return oauth2Login.StateHandler(config, success)
+}
+
+// LoginHandler handles OpenID Connect login requests by reading the state
+// value from the ctx and redirecting requests to the AuthURL with that state
+// value. The oauth2.Config Scopes should include "openid".
+func LoginHandler(config *oauth2.Config, failure http.Handler) http.Handler {
+	return oauth2Login.LoginHandler(config, failure)
+}
+
+// CallbackHandler handles OpenID Connect redirection URI requests and adds
+// the access token, raw ID token, and verified ID token Claims to the ctx.
+// If authentication succeeds, handling delegates to the success handler,
+// otherwise to the failure handler.
+func CallbackHandler(config *oauth2.Config, verifier *Verifier, success, failure http.Handler) http.Handler {
+	success = oidcHandler(verifier, success, failure)
+	return oauth2Login.CallbackHandler(config, success, failure)
+}
+
+// oidcHandler is a http.Handler that gets the OAuth2 Token from the ctx and
+// verifies its ID token. If successful, the ID token Claims are added to the
+// ctx and the success handler is called. Otherwise, the failure handler is
+// called.
+func oidcHandler(verifier *Verifier, success, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		rawIDToken, ok := token.Extra("id_token").(string)
+		if !ok || rawIDToken == "" {
+			ctx = gologin.WithError(ctx, ErrMissingIDToken)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		// an upstream handler may have sent a nonce with the login request
+		nonce, _ := oauth2Login.NonceFromContext(ctx)
+		claims, err := verifier.Verify(ctx, rawIDToken, nonce)
+		if err != nil {
+			ctx = gologin.WithError(ctx, err)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		ctx = WithIDToken(ctx, rawIDToken)
+		ctx = WithClaims(ctx, claims)
+		ctx = gologin.WithIdentity(ctx, newIdentity(claims))
+		success.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// newIdentity returns the gologin Identity of the ID token Claims. The
+// Provider is the issuer, since subjects are only unique per issuer.
+func newIdentity(claims *Claims) *gologin.Identity {
+	return &gologin.Identity{
+		Provider:      claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+		Username:      claims.PreferredUsername,
+		AvatarURL:     claims.Picture,
+		Raw:           claims,
+	}
+}
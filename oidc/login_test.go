@@ -0,0 +1,115 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin/v2"
+	oauth2Login "github.com/dghubble/gologin/v2/oauth2"
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestCallbackHandler(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+	issuer.idToken = issuer.sign(t, issuer.claims())
+	provider, err := Discover(context.Background(), issuer.URL)
+	assert.Nil(t, err)
+
+	config := &oauth2.Config{
+		ClientID: testClientID,
+		Endpoint: provider.Endpoint(),
+	}
+	success := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		token, err := oauth2Login.TokenFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "access-token", token.AccessToken)
+		claims, err := ClaimsFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "248289761001", claims.Subject)
+		assert.Equal(t, "Jane Doe", claims.Name)
+		rawIDToken, err := IDTokenFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, issuer.idToken, rawIDToken)
+		identity, err := gologin.IdentityFromContext(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, issuer.URL, identity.Provider)
+		assert.Equal(t, "248289761001", identity.Subject)
+		assert.Equal(t, "jane@example.com", identity.Email)
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := testutils.AssertFailureNotCalled(t)
+
+	// CallbackHandler assert that:
+	// - the auth code is exchanged for a Token with an ID token
+	// - the ID token is verified against the issuer's keys
+	// - success handler is called
+	// - ID token Claims are added to the ctx of the success handler
+	callbackHandler := CallbackHandler(config, provider.Verifier(testClientID), http.HandlerFunc(success), failure)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?code=any_code&state=d4e5f6", nil)
+	ctx := oauth2Login.WithState(context.Background(), "d4e5f6")
+	callbackHandler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "success handler called", w.Body.String())
+}
+
+func TestOIDCHandler_MissingIDToken(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+	provider, err := Discover(context.Background(), issuer.URL)
+	assert.Nil(t, err)
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.Equal(t, ErrMissingIDToken, err)
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// oidcHandler called with a Token without an ID token, assert that:
+	// - failure handler is called
+	// - error about the missing ID token is added to the ctx
+	handler := oidcHandler(provider.Verifier(testClientID), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithToken(context.Background(), &oauth2.Token{AccessToken: "access-token"})
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestOIDCHandler_InvalidNonce(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+	provider, err := Discover(context.Background(), issuer.URL)
+	assert.Nil(t, err)
+	claims := issuer.claims()
+	claims["nonce"] = "replayed-nonce"
+	token := (&oauth2.Token{AccessToken: "access-token"}).WithExtra(map[string]interface{}{
+		"id_token": issuer.sign(t, claims),
+	})
+
+	success := testutils.AssertSuccessNotCalled(t)
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		err := gologin.ErrorFromContext(req.Context())
+		assert.Equal(t, ErrInvalidNonce, err)
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	// oidcHandler called with a ctx nonce that differs from the ID token's,
+	// assert that:
+	// - failure handler is called
+	// - error about the invalid nonce is added to the ctx
+	handler := oidcHandler(provider.Verifier(testClientID), success, http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	ctx := oauth2Login.WithToken(context.Background(), token)
+	ctx = oauth2Login.WithNonce(ctx, "session-nonce")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
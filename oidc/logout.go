@@ -0,0 +1,153 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dghubble/gologin/v2"
+	oauth2Login "github.com/dghubble/gologin/v2/oauth2"
+)
+
+// backChannelLogoutEvent is the events member identifying logout tokens.
+const backChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// maxLogoutTokenAge limits how long after issue a logout token is accepted.
+const maxLogoutTokenAge = 5 * time.Minute
+
+// Logout errors
+var (
+	ErrEndSessionUnsupported = errors.New("oidc: issuer has no end_session_endpoint")
+	ErrInvalidLogoutToken    = errors.New("oidc: invalid logout token")
+)
+
+// LogoutHandler handles RP-initiated logout requests by redirecting to the
+// Provider's end_session_endpoint. The raw ID token in the ctx (see
+// WithIDToken), if any, is sent as the id_token_hint and the ctx state, if
+// any, is sent as the state. The issuer redirects the user back to the
+// postLogoutRedirectURL, which must be registered with the issuer, or shows
+// its own page if it is empty.
+//
+// Chain a StateHandler before LogoutHandler and handle the post logout
+// redirect with a StateHandler and LogoutCallbackHandler to validate the
+// state like a login callback.
+func LogoutHandler(provider *Provider, clientID, postLogoutRedirectURL string, failure http.Handler) http.Handler {
+	if failure == nil {
+		failure = gologin.DefaultFailureHandler
+	}
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		endSessionURL, err := url.Parse(provider.EndSessionURL)
+		if provider.EndSessionURL == "" || err != nil {
+			ctx = gologin.WithError(ctx, ErrEndSessionUnsupported)
+			failure.ServeHTTP(w, req.WithContext(ctx))
+			return
+		}
+		params := endSessionURL.Query()
+		params.Set("client_id", clientID)
+		if rawIDToken, err := IDTokenFromContext(ctx); err == nil {
+			params.Set("id_token_hint", rawIDToken)
+		}
+		if postLogoutRedirectURL != "" {
+			params.Set("post_logout_redirect_uri", postLogoutRedirectURL)
+		}
+		if state, err := oauth2Login.StateFromContext(ctx); err == nil {
+			params.Set("state", state)
+		}
+		endSessionURL.RawQuery = params.Encode()
+		http.Redirect(w, req, endSessionURL.String(), http.StatusFound)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// LogoutCallbackHandler handles post logout redirects from the issuer by
+// checking the state parameter against the ctx state, like a login callback.
+// If the state is valid, handling delegates to the success handler,
+// otherwise to the failure handler.
+func LogoutCallbackHandler(success, failure http.Handler) http.Handler {
+	return oauth2Login.ValidateStateHandler(success, failure)
+}
+
+// LogoutClaims are the claims of a verified back-channel logout token.
+type LogoutClaims struct {
+	Issuer    string                     `json:"iss"`
+	Subject   string                     `json:"sub,omitempty"`
+	Audience  audience                   `json:"aud"`
+	IssuedAt  int64                      `json:"iat"`
+	Expiry    int64                      `json:"exp,omitempty"`
+	JWTID     string                     `json:"jti"`
+	SessionID string                     `json:"sid,omitempty"`
+	Events    map[string]json.RawMessage `json:"events"`
+	Nonce     *string                    `json:"nonce,omitempty"`
+}
+
+// VerifyLogoutToken verifies the signature, issuer, audience, issued at time,
+// and events of a raw back-channel logout token and returns its Claims, per
+// OpenID Connect Back-Channel Logout 1.0. Logout tokens must identify a
+// subject or session and must not have a nonce.
+func (v *Verifier) VerifyLogoutToken(ctx context.Context, rawLogoutToken string) (*LogoutClaims, error) {
+	jwt, err := v.verifySignature(ctx, rawLogoutToken)
+	if err != nil {
+		return nil, err
+	}
+	claims := new(LogoutClaims)
+	if err := jwt.Claims(claims); err != nil {
+		return nil, ErrInvalidLogoutToken
+	}
+	if claims.Issuer != v.issuer {
+		return nil, ErrInvalidIssuer
+	}
+	if !claims.Audience.contains(v.clientID) {
+		return nil, ErrInvalidAudience
+	}
+	now := v.now()
+	issuedAt := time.Unix(claims.IssuedAt, 0)
+	if claims.IssuedAt == 0 || now.Add(allowedSkew).Before(issuedAt) || now.Add(-maxLogoutTokenAge).After(issuedAt) {
+		return nil, ErrInvalidIssuedAt
+	}
+	if claims.Expiry != 0 && now.Add(-allowedSkew).After(time.Unix(claims.Expiry, 0)) {
+		return nil, ErrInvalidLogoutToken
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(claims.Events[backChannelLogoutEvent], &event); err != nil || event == nil {
+		return nil, ErrInvalidLogoutToken
+	}
+	if claims.Nonce != nil {
+		return nil, ErrInvalidLogoutToken
+	}
+	if claims.Subject == "" && claims.SessionID == "" {
+		return nil, ErrInvalidLogoutToken
+	}
+	return claims, nil
+}
+
+// BackChannelLogoutHandler receives OpenID Connect back-channel logout
+// requests POSTed by the issuer. The logout_token is verified and the logout
+// func is called with its session ID and/or subject, so the app can end the
+// matching sessions. The issuer receives a 200 OK if logout succeeds or a
+// 400 Bad Request otherwise.
+func BackChannelLogoutHandler(verifier *Verifier, logout func(ctx context.Context, sid, sub string) error) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		if req.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ctx := req.Context()
+		claims, err := verifier.VerifyLogoutToken(ctx, req.PostFormValue("logout_token"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := logout(ctx, claims.SessionID, claims.Subject); err != nil {
+			http.Error(w, "oidc: logout failed", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,162 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin/v2"
+	oauth2Login "github.com/dghubble/gologin/v2/oauth2"
+	"github.com/dghubble/gologin/v2/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogoutHandler(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+	provider, err := Discover(context.Background(), issuer.URL)
+	assert.Nil(t, err)
+
+	// LogoutHandler with an ID token and state in the ctx, assert that:
+	// - redirects to the end_session_endpoint
+	// - sends the id_token_hint, post_logout_redirect_uri, state, and client_id
+	handler := LogoutHandler(provider, testClientID, "https://app.example.com/logged-out", testutils.AssertFailureNotCalled(t))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/logout", nil)
+	ctx := WithIDToken(req.Context(), "raw-id-token")
+	ctx = oauth2Login.WithState(ctx, "state_val")
+	handler.ServeHTTP(w, req.WithContext(ctx))
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	assert.Nil(t, err)
+	assert.Equal(t, issuer.URL+"/logout", location.Scheme+"://"+location.Host+location.Path)
+	params := location.Query()
+	assert.Equal(t, "compact", params.Get("ui"))
+	assert.Equal(t, "raw-id-token", params.Get("id_token_hint"))
+	assert.Equal(t, "https://app.example.com/logged-out", params.Get("post_logout_redirect_uri"))
+	assert.Equal(t, "state_val", params.Get("state"))
+	assert.Equal(t, testClientID, params.Get("client_id"))
+}
+
+func TestLogoutHandler_Unsupported(t *testing.T) {
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, ErrEndSessionUnsupported, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+
+	handler := LogoutHandler(&Provider{}, testClientID, "", http.HandlerFunc(failure))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/logout", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestLogoutCallbackHandler(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	success := func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "success handler called")
+	}
+	failure := func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, oauth2Login.ErrInvalidState, gologin.ErrorFromContext(req.Context()))
+		fmt.Fprintf(w, "failure handler called")
+	}
+	handler := StateHandler(config, LogoutCallbackHandler(http.HandlerFunc(success), http.HandlerFunc(failure)))
+
+	// post logout redirect with the state of the state cookie
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/logged-out?state=state_val", nil)
+	req.AddCookie(&http.Cookie{Name: config.Name, Value: "state_val"})
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "success handler called", w.Body.String())
+
+	// post logout redirect with another state
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/logged-out?state=other", nil)
+	req.AddCookie(&http.Cookie{Name: config.Name, Value: "state_val"})
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "failure handler called", w.Body.String())
+}
+
+func TestVerifyLogoutToken(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+	provider, err := Discover(context.Background(), issuer.URL)
+	assert.Nil(t, err)
+	verifier := provider.Verifier(testClientID)
+
+	claims, err := verifier.VerifyLogoutToken(context.Background(), issuer.sign(t, issuer.logoutClaims()))
+	assert.Nil(t, err)
+	assert.Equal(t, "248289761001", claims.Subject)
+	assert.Equal(t, "08a5019c-17e1-4977-8f42-65a12843ea02", claims.SessionID)
+
+	cases := []struct {
+		modify func(claims map[string]interface{})
+		err    error
+	}{
+		{func(c map[string]interface{}) { c["iss"] = "https://other.example.com" }, ErrInvalidIssuer},
+		{func(c map[string]interface{}) { c["aud"] = "other-client" }, ErrInvalidAudience},
+		{func(c map[string]interface{}) { c["iat"] = time.Now().Add(-time.Hour).Unix() }, ErrInvalidIssuedAt},
+		{func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, ErrInvalidLogoutToken},
+		{func(c map[string]interface{}) { delete(c, "events") }, ErrInvalidLogoutToken},
+		{func(c map[string]interface{}) { c["events"] = map[string]interface{}{backChannelLogoutEvent: "logout"} }, ErrInvalidLogoutToken},
+		{func(c map[string]interface{}) { c["nonce"] = "nonce" }, ErrInvalidLogoutToken},
+		{func(c map[string]interface{}) { delete(c, "sub"); delete(c, "sid") }, ErrInvalidLogoutToken},
+	}
+	for _, c := range cases {
+		logoutClaims := issuer.logoutClaims()
+		c.modify(logoutClaims)
+		_, err := verifier.VerifyLogoutToken(context.Background(), issuer.sign(t, logoutClaims))
+		assert.Equal(t, c.err, err)
+	}
+}
+
+func TestBackChannelLogoutHandler(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+	provider, err := Discover(context.Background(), issuer.URL)
+	assert.Nil(t, err)
+	var loggedOut []string
+	logout := func(ctx context.Context, sid, sub string) error {
+		if sub == "unknown" {
+			return errors.New("unknown user")
+		}
+		loggedOut = append(loggedOut, sid, sub)
+		return nil
+	}
+	handler := BackChannelLogoutHandler(provider.Verifier(testClientID), logout)
+	post := func(logoutToken string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		body := url.Values{"logout_token": {logoutToken}}.Encode()
+		req, _ := http.NewRequest("POST", "/backchannel-logout", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// BackChannelLogoutHandler with a valid logout token, assert that:
+	// - the logout func is called with the sid and sub
+	// - the issuer receives a 200 OK which is not cached
+	w := post(issuer.sign(t, issuer.logoutClaims()))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Equal(t, []string{"08a5019c-17e1-4977-8f42-65a12843ea02", "248289761001"}, loggedOut)
+
+	// invalid logout tokens and failed logouts receive a 400 Bad Request
+	assert.Equal(t, http.StatusBadRequest, post("not-a-jwt").Code)
+	claims := issuer.logoutClaims()
+	claims["sub"] = "unknown"
+	assert.Equal(t, http.StatusBadRequest, post(issuer.sign(t, claims)).Code)
+	assert.Len(t, loggedOut, 2)
+
+	// only POST is allowed
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/backchannel-logout", nil)
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -0,0 +1,187 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dghubble/gologin/v2/internal/jose"
+	"golang.org/x/oauth2"
+)
+
+// defaultSigningAlg is the ID token signing algorithm of issuers which do not
+// list id_token_signing_alg_values_supported (OpenID Connect Core 3.1.3.7).
+const defaultSigningAlg = "RS256"
+
+// minKeyRefresh limits how often an unknown key ID may trigger fetching the
+// issuer's JSON Web Key Set.
+const minKeyRefresh = time.Minute
+
+// Provider is an OpenID Connect issuer's discovered metadata.
+type Provider struct {
+	Issuer                string   `json:"issuer"`
+	AuthURL               string   `json:"authorization_endpoint"`
+	TokenURL              string   `json:"token_endpoint"`
+	UserInfoURL           string   `json:"userinfo_endpoint"`
+	JWKSURL               string   `json:"jwks_uri"`
+	EndSessionURL         string   `json:"end_session_endpoint"`
+	ScopesSupported       []string `json:"scopes_supported"`
+	SigningAlgsSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ResponseModeSupported []string `json:"response_modes_supported"`
+	// IssParameterSupported indicates the issuer sends the RFC 9207 "iss"
+	// callback parameter, which oauth2 IssuerHandler can require.
+	IssParameterSupported bool `json:"authorization_response_iss_parameter_supported"`
+	// PushedAuthURL is the Pushed Authorization Request (RFC 9126) endpoint,
+	// for use with oauth2 PushedAuthHandler.
+	PushedAuthURL string `json:"pushed_authorization_request_endpoint"`
+	// RequirePushedAuth indicates the issuer only accepts pushed
+	// authorization requests.
+	RequirePushedAuth bool `json:"require_pushed_authorization_requests"`
+
+	keys *keySet
+}
+
+// Discover fetches the OpenID Provider Configuration of the issuer from its
+// ".well-known/openid-configuration" document. Requests use the http.Client
+// in the ctx under oauth2.HTTPClient, if any.
+func Discover(ctx context.Context, issuer string) (*Provider, error) {
+	wellKnown := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	provider := new(Provider)
+	if err := getJSON(ctx, wellKnown, provider); err != nil {
+		return nil, fmt.Errorf("oidc: unable to discover issuer: %v", err)
+	}
+	if provider.Issuer != issuer {
+		return nil, fmt.Errorf("oidc: discovered issuer %q does not match %q", provider.Issuer, issuer)
+	}
+	if provider.JWKSURL == "" {
+		return nil, fmt.Errorf("oidc: issuer %q has no jwks_uri", issuer)
+	}
+	provider.keys = &keySet{url: provider.JWKSURL}
+	return provider, nil
+}
+
+// Endpoint returns the OAuth2 Endpoint of the Provider.
+func (p *Provider) Endpoint() oauth2.Endpoint {
+	return oauth2.Endpoint{
+		AuthURL:  p.AuthURL,
+		TokenURL: p.TokenURL,
+	}
+}
+
+// Verifier returns a Verifier of ID tokens issued by the Provider to the
+// given OAuth2 client ID.
+func (p *Provider) Verifier(clientID string) *Verifier {
+	algs := p.SigningAlgsSupported
+	if len(algs) == 0 {
+		algs = []string{defaultSigningAlg}
+	}
+	return &Verifier{
+		issuer:   p.Issuer,
+		clientID: clientID,
+		algs:     algs,
+		keys:     p.keys,
+		now:      time.Now,
+	}
+}
+
+// keySet caches an issuer's JSON Web Key Set, refetching it when a token is
+// signed by a key ID which is not yet known (i.e. the issuer rotated keys).
+type keySet struct {
+	url string
+
+	mu      sync.Mutex
+	keys    map[string]signingKey
+	fetched time.Time
+}
+
+// signingKey is an issuer's public key and the algorithm it is restricted to
+// by its JSON Web Key "alg", if any.
+type signingKey struct {
+	key crypto.PublicKey
+	alg string
+}
+
+// key returns the public key with the given key ID. An empty key ID matches
+// the issuer's key only if it publishes exactly one.
+func (s *keySet) key(ctx context.Context, kid string) (signingKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	if time.Since(s.fetched) < minKeyRefresh {
+		return signingKey{}, ErrUnknownKey
+	}
+	if err := s.refresh(ctx); err != nil {
+		return signingKey{}, err
+	}
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	return signingKey{}, ErrUnknownKey
+}
+
+func (s *keySet) lookup(kid string) (signingKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// refresh fetches the JSON Web Key Set. Keys of unsupported types or keys
+// not intended for signatures are skipped.
+func (s *keySet) refresh(ctx context.Context) error {
+	jwks := new(jose.JSONWebKeySet)
+	if err := getJSON(ctx, s.url, jwks); err != nil {
+		return fmt.Errorf("oidc: unable to fetch keys: %v", err)
+	}
+	keys := make(map[string]signingKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = signingKey{key: key, alg: jwk.Algorithm}
+	}
+	s.keys = keys
+	s.fetched = time.Now()
+	return nil
+}
+
+// getJSON gets the url and decodes the JSON response into v.
+func getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient(ctx).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// httpClient returns the http.Client in the ctx under oauth2.HTTPClient, like
+// golang.org/x/oauth2, or the http.DefaultClient.
+func httpClient(ctx context.Context) *http.Client {
+	if client, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok {
+		return client
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,114 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin/v2/internal/jose"
+)
+
+const testClientID = "client-id"
+
+// testIssuer is a local OpenID Connect issuer for tests.
+type testIssuer struct {
+	*httptest.Server
+	key *ecdsa.PrivateKey
+	// rsaKey is published as "key-2" with an "alg" of RS256
+	rsaKey *rsa.PrivateKey
+	// idToken is returned by the token endpoint
+	idToken string
+}
+
+// newTestIssuer returns a new testIssuer which serves discovery, keys, and
+// token endpoints. The caller must close the server.
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &testIssuer{key: key, rsaKey: rsaKey}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"issuer":                                issuer.URL,
+			"authorization_endpoint":                issuer.URL + "/authorize",
+			"token_endpoint":                        issuer.URL + "/token",
+			"jwks_uri":                              issuer.URL + "/keys",
+			"end_session_endpoint":                  issuer.URL + "/logout?ui=compact",
+			"pushed_authorization_request_endpoint": issuer.URL + "/par",
+			"id_token_signing_alg_values_supported": []string{"ES256", "RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, req *http.Request) {
+		jwk, _ := jose.NewJSONWebKey(&key.PublicKey)
+		jwk.KeyID = "key-1"
+		jwk.Use = "sig"
+		rsaJWK, _ := jose.NewJSONWebKey(&rsaKey.PublicKey)
+		rsaJWK.KeyID = "key-2"
+		rsaJWK.Algorithm = "RS256"
+		writeJSON(w, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{*jwk, *rsaJWK}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"id_token":     issuer.idToken,
+		})
+	})
+	issuer.Server = httptest.NewServer(mux)
+	return issuer
+}
+
+// claims returns valid ID token claims issued now by the testIssuer.
+func (i *testIssuer) claims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":   i.URL,
+		"sub":   "248289761001",
+		"aud":   testClientID,
+		"exp":   now.Add(time.Hour).Unix(),
+		"iat":   now.Unix(),
+		"email": "jane@example.com",
+		"name":  "Jane Doe",
+	}
+}
+
+// sign returns an ID token with the given claims signed by the testIssuer.
+func (i *testIssuer) sign(t *testing.T, claims map[string]interface{}) string {
+	raw, err := jose.Sign(i.key, jose.Header{KeyID: "key-1", Type: "JWT"}, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// logoutClaims returns valid logout token claims issued now by the testIssuer.
+func (i *testIssuer) logoutClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss": i.URL,
+		"sub": "248289761001",
+		"aud": testClientID,
+		"iat": time.Now().Unix(),
+		"jti": "bWJq",
+		"sid": "08a5019c-17e1-4977-8f42-65a12843ea02",
+		"events": map[string]interface{}{
+			"http://schemas.openid.net/event/backchannel-logout": map[string]interface{}{},
+		},
+	}
+}
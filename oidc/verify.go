@@ -0,0 +1,154 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/dghubble/gologin/v2/internal/jose"
+)
+
+// allowedSkew is the tolerated clock skew when checking ID token times.
+const allowedSkew = time.Minute
+
+// ID token verification errors
+var (
+	ErrUnknownKey       = errors.New("oidc: ID token signed by an unknown key")
+	ErrInvalidIDToken   = errors.New("oidc: invalid ID token")
+	ErrInvalidIssuer    = errors.New("oidc: ID token has an invalid issuer")
+	ErrInvalidAudience  = errors.New("oidc: ID token has an invalid audience")
+	ErrIDTokenExpired   = errors.New("oidc: ID token is expired")
+	ErrInvalidIssuedAt  = errors.New("oidc: ID token has an invalid issued at time")
+	ErrInvalidNonce     = errors.New("oidc: ID token has an invalid nonce")
+	ErrMissingIDToken   = errors.New("oidc: Token response missing id_token")
+	ErrInvalidSignature = errors.New("oidc: ID token has an invalid signature")
+	ErrUnsupportedAlg   = errors.New("oidc: ID token signed with an unsupported algorithm")
+)
+
+// Claims are the claims of a verified ID token.
+type Claims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"`
+	Audience        audience `json:"aud"`
+	Expiry          int64    `json:"exp"`
+	IssuedAt        int64    `json:"iat"`
+	Nonce           string   `json:"nonce,omitempty"`
+	AuthorizedParty string   `json:"azp,omitempty"`
+	SessionID       string   `json:"sid,omitempty"`
+
+	Email             string `json:"email,omitempty"`
+	EmailVerified     bool   `json:"email_verified,omitempty"`
+	Name              string `json:"name,omitempty"`
+	GivenName         string `json:"given_name,omitempty"`
+	FamilyName        string `json:"family_name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Picture           string `json:"picture,omitempty"`
+
+	// Raw holds all claims, including non-standard claims.
+	Raw map[string]interface{} `json:"-"`
+}
+
+// audience is an "aud" claim, which may be a single string or an array.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(b, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	return contains(a, s)
+}
+
+// Verifier verifies ID tokens issued by a Provider to a client.
+type Verifier struct {
+	issuer   string
+	clientID string
+	// algs are the issuer's supported signing algorithms
+	algs []string
+	keys *keySet
+	now  func() time.Time
+}
+
+// Verify verifies the signature, issuer, audience, expiry and issued at time
+// of a raw ID token and returns its Claims. If nonce is non-empty, the ID
+// token nonce claim must match it.
+func (v *Verifier) Verify(ctx context.Context, rawIDToken, nonce string) (*Claims, error) {
+	jwt, err := v.verifySignature(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := new(Claims)
+	if err := jwt.Claims(claims); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	if err := jwt.Claims(&claims.Raw); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	if claims.Issuer != v.issuer {
+		return nil, ErrInvalidIssuer
+	}
+	if !claims.Audience.contains(v.clientID) {
+		return nil, ErrInvalidAudience
+	}
+	// OpenID Connect Core 3.1.3.7: with multiple audiences, azp must be the client
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != v.clientID {
+		return nil, ErrInvalidAudience
+	}
+	now := v.now()
+	if claims.Expiry == 0 || now.Add(-allowedSkew).After(time.Unix(claims.Expiry, 0)) {
+		return nil, ErrIDTokenExpired
+	}
+	if claims.IssuedAt == 0 || now.Add(allowedSkew).Before(time.Unix(claims.IssuedAt, 0)) {
+		return nil, ErrInvalidIssuedAt
+	}
+	if nonce != "" && claims.Nonce != nonce {
+		return nil, ErrInvalidNonce
+	}
+	return claims, nil
+}
+
+// verifySignature parses a raw JWT and verifies it was signed by one of the
+// issuer's keys with one of the issuer's supported algorithms. Keys whose
+// JSON Web Key names an "alg" only verify that algorithm.
+func (v *Verifier) verifySignature(ctx context.Context, raw string) (*jose.JWT, error) {
+	jwt, err := jose.Parse(raw)
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	if !contains(v.algs, jwt.Header.Algorithm) {
+		return nil, ErrUnsupportedAlg
+	}
+	key, err := v.keys.key(ctx, jwt.Header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.alg != "" && key.alg != jwt.Header.Algorithm {
+		return nil, ErrInvalidSignature
+	}
+	if err := jwt.Verify(key.key); err != nil {
+		return nil, ErrInvalidSignature
+	}
+	return jwt, nil
+}
+
+// contains returns true if the values contain s.
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin/v2/internal/jose"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscover(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+
+	provider, err := Discover(context.Background(), issuer.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, issuer.URL, provider.Issuer)
+	assert.Equal(t, issuer.URL+"/authorize", provider.Endpoint().AuthURL)
+	assert.Equal(t, issuer.URL+"/token", provider.Endpoint().TokenURL)
+	assert.Equal(t, issuer.URL+"/par", provider.PushedAuthURL)
+}
+
+func TestDiscover_IssuerMismatch(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+
+	_, err := Discover(context.Background(), issuer.URL+"/other")
+	assert.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+	provider, err := Discover(context.Background(), issuer.URL)
+	assert.Nil(t, err)
+	verifier := provider.Verifier(testClientID)
+
+	claims := issuer.claims()
+	claims["nonce"] = "nonce-1"
+	verified, err := verifier.Verify(context.Background(), issuer.sign(t, claims), "nonce-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "248289761001", verified.Subject)
+	assert.Equal(t, "jane@example.com", verified.Email)
+	assert.Equal(t, "Jane Doe", verified.Raw["name"])
+}
+
+func TestVerify_RSAKey(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+	provider, err := Discover(context.Background(), issuer.URL)
+	assert.Nil(t, err)
+
+	// ID token signed by a key with a JWK alg, using that alg
+	rawToken, err := jose.Sign(issuer.rsaKey, jose.Header{KeyID: "key-2", Algorithm: "RS256"}, issuer.claims())
+	assert.Nil(t, err)
+	_, err = provider.Verifier(testClientID).Verify(context.Background(), rawToken, "")
+	assert.Nil(t, err)
+}
+
+func TestVerify_SigningAlgsSupported(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+	provider, err := Discover(context.Background(), issuer.URL)
+	assert.Nil(t, err)
+	rawToken := issuer.sign(t, issuer.claims())
+
+	// ES256 ID token from an issuer which only supports RS256
+	provider.SigningAlgsSupported = []string{"RS256"}
+	_, err = provider.Verifier(testClientID).Verify(context.Background(), rawToken, "")
+	assert.Equal(t, ErrUnsupportedAlg, err)
+
+	// issuers which list no algorithms only support RS256
+	provider.SigningAlgsSupported = nil
+	_, err = provider.Verifier(testClientID).Verify(context.Background(), rawToken, "")
+	assert.Equal(t, ErrUnsupportedAlg, err)
+}
+
+func TestVerify_Errors(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+	provider, err := Discover(context.Background(), issuer.URL)
+	assert.Nil(t, err)
+	verifier := provider.Verifier(testClientID)
+
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	forged, _ := jose.Sign(otherKey, jose.Header{KeyID: "key-1"}, issuer.claims())
+	// algorithms the issuer supports, but which its keys may not use
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	wrongCurve, _ := jose.Sign(p384Key, jose.Header{KeyID: "key-1", Algorithm: "ES256"}, issuer.claims())
+	wrongHash, _ := jose.Sign(issuer.key, jose.Header{KeyID: "key-1", Algorithm: "ES384"}, issuer.claims())
+	unpinned, _ := jose.Sign(issuer.rsaKey, jose.Header{KeyID: "key-2", Algorithm: "PS256"}, issuer.claims())
+	wrongType, _ := jose.Sign(issuer.rsaKey, jose.Header{KeyID: "key-1", Algorithm: "RS256"}, issuer.claims())
+	unsupported, _ := jose.Sign(issuer.key, jose.Header{KeyID: "key-1", Algorithm: "ES512"}, issuer.claims())
+	provider.SigningAlgsSupported = []string{"ES256", "ES384", "RS256", "PS256"}
+	verifier = provider.Verifier(testClientID)
+
+	cases := []struct {
+		name     string
+		modify   func(claims map[string]interface{})
+		rawToken string
+		nonce    string
+		expected error
+	}{
+		{name: "malformed", rawToken: "not.a.jwt", expected: ErrInvalidIDToken},
+		{name: "forged", rawToken: forged, expected: ErrInvalidSignature},
+		{name: "ES256 with a P-384 key", rawToken: wrongCurve, expected: ErrInvalidSignature},
+		{name: "ES384 with a P-256 key", rawToken: wrongHash, expected: ErrInvalidSignature},
+		{name: "PS256 with an RS256 JWK", rawToken: unpinned, expected: ErrInvalidSignature},
+		{name: "RS256 with an EC key", rawToken: wrongType, expected: ErrInvalidSignature},
+		{name: "unsupported alg", rawToken: unsupported, expected: ErrUnsupportedAlg},
+		{name: "issuer", modify: func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }, expected: ErrInvalidIssuer},
+		{name: "audience", modify: func(c map[string]interface{}) { c["aud"] = "other-client" }, expected: ErrInvalidAudience},
+		{name: "azp", modify: func(c map[string]interface{}) { c["aud"] = []string{testClientID, "other-client"} }, expected: ErrInvalidAudience},
+		{name: "expired", modify: func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, expected: ErrIDTokenExpired},
+		{name: "future iat", modify: func(c map[string]interface{}) { c["iat"] = time.Now().Add(time.Hour).Unix() }, expected: ErrInvalidIssuedAt},
+		{name: "nonce", nonce: "expected-nonce", expected: ErrInvalidNonce},
+	}
+	for _, c := range cases {
+		rawToken := c.rawToken
+		if rawToken == "" {
+			claims := issuer.claims()
+			if c.modify != nil {
+				c.modify(claims)
+			}
+			rawToken = issuer.sign(t, claims)
+		}
+		_, err := verifier.Verify(context.Background(), rawToken, c.nonce)
+		assert.Equal(t, c.expected, err, c.name)
+	}
+}
@@ -0,0 +1,112 @@
+package gologin
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Provider is an authentication provider whose login and callback handlers
+// may be mounted by a Registry. Provider packages (e.g. github) provide a
+// NewProvider function.
+type Provider interface {
+	// LoginHandler returns the handler for login requests.
+	LoginHandler(failure http.Handler) http.Handler
+	// CallbackHandler returns the handler for callback requests.
+	CallbackHandler(success, failure http.Handler) http.Handler
+}
+
+// ProviderFuncs adapts login and callback handler constructors to a
+// Provider, for registering custom providers.
+type ProviderFuncs struct {
+	Login    func(failure http.Handler) http.Handler
+	Callback func(success, failure http.Handler) http.Handler
+}
+
+// LoginHandler calls Login.
+func (p ProviderFuncs) LoginHandler(failure http.Handler) http.Handler {
+	return p.Login(failure)
+}
+
+// CallbackHandler calls Callback.
+func (p ProviderFuncs) CallbackHandler(success, failure http.Handler) http.Handler {
+	return p.Callback(success, failure)
+}
+
+// Registry is a set of named Providers which mounts their login and callback
+// handlers with shared success and failure handlers. The zero value is an
+// empty Registry ready to use.
+type Registry struct {
+	// PathPrefix is the path under which provider routes are mounted.
+	// Defaults to "/auth" when left zero valued.
+	PathPrefix string
+
+	providers map[string]Provider
+}
+
+// NewRegistry returns a new, empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		providers: make(map[string]Provider),
+	}
+}
+
+// Register adds the Provider under the given name. Register panics if the
+// name is empty, contains a "/", or is already registered.
+func (r *Registry) Register(name string, provider Provider) {
+	if name == "" || strings.Contains(name, "/") {
+		panic(fmt.Sprintf("gologin: invalid provider name %q", name))
+	}
+	if _, exists := r.providers[name]; exists {
+		panic(fmt.Sprintf("gologin: provider %q already registered", name))
+	}
+	if r.providers == nil {
+		r.providers = make(map[string]Provider)
+	}
+	r.providers[name] = provider
+}
+
+// Provider returns the Provider registered under the given name.
+func (r *Registry) Provider(name string) (Provider, bool) {
+	provider, ok := r.providers[name]
+	return provider, ok
+}
+
+// Names returns the sorted names of registered Providers.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Mount registers each Provider's login handler at
+// "{PathPrefix}/{name}/login" and callback handler at
+// "{PathPrefix}/{name}/callback" on the mux. The provider name is added to
+// the ctx (see ProviderNameFromContext) before handling.
+//
+// Each provider's OAuth redirect URL must be configured to match its
+// callback route.
+func (r *Registry) Mount(mux *http.ServeMux, success, failure http.Handler) {
+	prefix := strings.TrimSuffix(r.PathPrefix, "/")
+	if prefix == "" {
+		prefix = "/auth"
+	}
+	for _, name := range r.Names() {
+		provider := r.providers[name]
+		mux.Handle(prefix+"/"+name+"/login", providerNameHandler(name, provider.LoginHandler(failure)))
+		mux.Handle(prefix+"/"+name+"/callback", providerNameHandler(name, provider.CallbackHandler(success, failure)))
+	}
+}
+
+// providerNameHandler adds the provider name to the ctx.
+func providerNameHandler(name string, next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, req *http.Request) {
+		ctx := WithProviderName(req.Context(), name)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
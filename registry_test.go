@@ -0,0 +1,97 @@
+package gologin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestProvider returns a Provider whose handlers write the provider name
+// from the ctx.
+func newTestProvider() Provider {
+	return ProviderFuncs{
+		Login: func(failure http.Handler) http.Handler {
+			fn := func(w http.ResponseWriter, req *http.Request) {
+				name, _ := ProviderNameFromContext(req.Context())
+				fmt.Fprintf(w, "%s login", name)
+			}
+			return http.HandlerFunc(fn)
+		},
+		Callback: func(success, failure http.Handler) http.Handler {
+			return success
+		},
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("github", newTestProvider())
+	registry.Register("custom", newTestProvider())
+	assert.Equal(t, []string{"custom", "github"}, registry.Names())
+	_, ok := registry.Provider("github")
+	assert.True(t, ok)
+	_, ok = registry.Provider("google")
+	assert.False(t, ok)
+
+	success := func(w http.ResponseWriter, req *http.Request) {
+		name, err := ProviderNameFromContext(req.Context())
+		assert.Nil(t, err)
+		fmt.Fprintf(w, "%s success", name)
+	}
+
+	// Registry Mount assert that:
+	// - login and callback routes are mounted for each provider
+	// - the provider name is added to the ctx
+	// - callback handlers call the shared success handler
+	mux := http.NewServeMux()
+	registry.Mount(mux, http.HandlerFunc(success), nil)
+	for path, expected := range map[string]string{
+		"/auth/github/login":    "github login",
+		"/auth/github/callback": "github success",
+		"/auth/custom/login":    "custom login",
+		"/auth/custom/callback": "custom success",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		mux.ServeHTTP(w, req)
+		assert.Equal(t, expected, w.Body.String())
+	}
+}
+
+func TestRegistry_PathPrefix(t *testing.T) {
+	registry := NewRegistry()
+	registry.PathPrefix = "/oauth/"
+	registry.Register("github", newTestProvider())
+
+	mux := http.NewServeMux()
+	registry.Mount(mux, DefaultFailureHandler, nil)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/oauth/github/login", nil)
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, "github login", w.Body.String())
+}
+
+func TestRegistry_ZeroValue(t *testing.T) {
+	registry := &Registry{PathPrefix: "/login"}
+	assert.Empty(t, registry.Names())
+	registry.Register("github", newTestProvider())
+	assert.Equal(t, []string{"github"}, registry.Names())
+
+	mux := http.NewServeMux()
+	registry.Mount(mux, DefaultFailureHandler, nil)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/login/github/login", nil)
+	mux.ServeHTTP(w, req)
+	assert.Equal(t, "github login", w.Body.String())
+}
+
+func TestRegistry_RegisterPanics(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("github", newTestProvider())
+	assert.Panics(t, func() { registry.Register("github", newTestProvider()) })
+	assert.Panics(t, func() { registry.Register("", newTestProvider()) })
+	assert.Panics(t, func() { registry.Register("a/b", newTestProvider()) })
+}
@@ -0,0 +1,33 @@
+package gologin
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrStateNotFound is returned by a StateStore when no unexpired value is
+// stored under a key.
+var ErrStateNotFound = errors.New("gologin: state not found")
+
+// StateStore persists short-lived values between the login phase and the
+// callback phase of an authorization flow (e.g. OAuth2 state values or
+// OAuth1 request token secrets). Stores expire values after a TTL of their
+// choosing.
+//
+// Values are single-use: handlers Load a value and then Delete it, and only
+// accept the value if the Delete succeeds. Delete must remove the value and
+// report whether it was present in one atomic step, so that of several
+// concurrent requests with the same key only one succeeds.
+//
+// The store package provides cookie and in-memory implementations. Apps
+// served by several instances may implement a StateStore backed by a shared
+// database, whose Delete checks the number of rows (or keys) it removed.
+type StateStore interface {
+	// Save stores the value under the key.
+	Save(w http.ResponseWriter, req *http.Request, key, value string) error
+	// Load returns the value stored under the key or ErrStateNotFound.
+	Load(req *http.Request, key string) (string, error)
+	// Delete removes the value stored under the key or returns
+	// ErrStateNotFound if no unexpired value was stored under the key.
+	Delete(w http.ResponseWriter, req *http.Request, key string) error
+}
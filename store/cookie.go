@@ -0,0 +1,58 @@
+package store
+
+import (
+	"net/http"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/dghubble/gologin/v2/internal"
+)
+
+// CookieStore is a gologin.StateStore which keeps a value in a short-lived
+// cookie issued to the requester, as StateHandler and CookieTempHandler do.
+// The cookie MaxAge is the TTL.
+//
+// A browser holds one value per cookie name, so keys are not part of the
+// cookie and Load returns the cookie value regardless of the key. Callers
+// must compare the loaded value to the value they expect. Delete can only
+// ask the browser to expire the cookie, so values are not single-use across
+// concurrent requests. Use a server-side store where that matters.
+type CookieStore struct {
+	config gologin.CookieConfig
+}
+
+// NewCookieStore returns a new CookieStore with the given CookieConfig.
+func NewCookieStore(config gologin.CookieConfig) *CookieStore {
+	internal.MustValidConfig(config)
+	return &CookieStore{config: config}
+}
+
+// Save issues a cookie with the value.
+func (s *CookieStore) Save(w http.ResponseWriter, req *http.Request, key, value string) error {
+	http.SetCookie(w, internal.NewCookie(s.config, value))
+	return nil
+}
+
+// Load reads the value from the request cookie. If the cookie is signed or
+// encrypted but cannot be verified, gologin.ErrInvalidCookie is returned.
+func (s *CookieStore) Load(req *http.Request, key string) (string, error) {
+	value, err := internal.ReadCookie(req, s.config)
+	if err == gologin.ErrInvalidCookie {
+		return "", err
+	}
+	if err != nil {
+		return "", gologin.ErrStateNotFound
+	}
+	return value, nil
+}
+
+// Delete expires the cookie. If the request has no cookie,
+// gologin.ErrStateNotFound is returned.
+func (s *CookieStore) Delete(w http.ResponseWriter, req *http.Request, key string) error {
+	if _, err := req.Cookie(s.config.Name); err != nil {
+		return gologin.ErrStateNotFound
+	}
+	config := s.config
+	config.MaxAge = -1
+	http.SetCookie(w, internal.NewCookie(config, ""))
+	return nil
+}
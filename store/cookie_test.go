@@ -0,0 +1,106 @@
+package store
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCookieStore(t *testing.T) {
+	store := NewCookieStore(gologin.DebugOnlyCookieConfig)
+
+	// Save issues a cookie with the value
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	assert.Nil(t, store.Save(w, req, "key", "value"))
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "gologin-temporary-cookie", cookies[0].Name)
+		assert.Equal(t, "value", cookies[0].Value)
+		assert.Equal(t, 600, cookies[0].MaxAge)
+	}
+
+	// Load reads the cookie value
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.AddCookie(cookies[0])
+	value, err := store.Load(req, "key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", value)
+
+	// Delete expires the cookie
+	w = httptest.NewRecorder()
+	assert.Nil(t, store.Delete(w, req, "key"))
+	cookies = w.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "gologin-temporary-cookie", cookies[0].Name)
+		assert.Equal(t, -1, cookies[0].MaxAge)
+	}
+}
+
+func TestCookieStore_NotFound(t *testing.T) {
+	store := NewCookieStore(gologin.DebugOnlyCookieConfig)
+	req, _ := http.NewRequest("GET", "/", nil)
+	value, err := store.Load(req, "key")
+	assert.Equal(t, "", value)
+	assert.Equal(t, gologin.ErrStateNotFound, err)
+	assert.Equal(t, gologin.ErrStateNotFound, store.Delete(httptest.NewRecorder(), req, "key"))
+}
+
+func TestCookieStore_SignedAndEncrypted(t *testing.T) {
+	config := gologin.DebugOnlyCookieConfig
+	config.SigningKeys = [][]byte{[]byte("new-signing-key"), []byte("old-signing-key")}
+	config.EncryptionKeys = [][]byte{[]byte("0123456789abcdef")}
+	store := NewCookieStore(config)
+
+	// Save issues a cookie with an opaque value, assert that:
+	// - the value is not readable in the cookie
+	// - Load verifies and decrypts the value
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	assert.Nil(t, store.Save(w, req, "key", "secret"))
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.NotContains(t, cookies[0].Value, "secret")
+	}
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.AddCookie(cookies[0])
+	value, err := store.Load(req, "key")
+	assert.Nil(t, err)
+	assert.Equal(t, "secret", value)
+
+	// Load rejects tampered or unsigned values
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: config.Name, Value: cookies[0].Value + "x"})
+	_, err = store.Load(req, "key")
+	assert.Equal(t, gologin.ErrInvalidCookie, err)
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: config.Name, Value: "secret"})
+	_, err = store.Load(req, "key")
+	assert.Equal(t, gologin.ErrInvalidCookie, err)
+}
+
+func TestCookieStore_KeyRotation(t *testing.T) {
+	old := gologin.DebugOnlyCookieConfig
+	old.SigningKeys = [][]byte{[]byte("old-signing-key")}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	assert.Nil(t, NewCookieStore(old).Save(w, req, "key", "value"))
+
+	// cookies signed by a rotated out key still verify
+	rotated := old
+	rotated.SigningKeys = [][]byte{[]byte("new-signing-key"), []byte("old-signing-key")}
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.AddCookie(w.Result().Cookies()[0])
+	value, err := NewCookieStore(rotated).Load(req, "key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", value)
+
+	// cookies signed by a removed key are rejected
+	removed := old
+	removed.SigningKeys = [][]byte{[]byte("new-signing-key")}
+	_, err = NewCookieStore(removed).Load(req, "key")
+	assert.Equal(t, gologin.ErrInvalidCookie, err)
+}
@@ -0,0 +1,2 @@
+// Package store provides gologin StateStore implementations.
+package store
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dghubble/gologin/v2"
+)
+
+// MemoryStore is a gologin.StateStore which keeps values in memory until
+// they are deleted or expire. It is suitable for apps served by a single
+// instance.
+type MemoryStore struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+type entry struct {
+	value   string
+	expires time.Time
+}
+
+// NewMemoryStore returns a new MemoryStore whose values expire after ttl.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]entry),
+	}
+}
+
+// Save stores the value under the key until the TTL elapses.
+func (s *MemoryStore) Save(w http.ResponseWriter, req *http.Request, key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	// sweep expired entries so abandoned flows do not accumulate
+	for k, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = entry{value: value, expires: now.Add(s.ttl)}
+	return nil
+}
+
+// Load returns the unexpired value stored under the key.
+func (s *MemoryStore) Load(req *http.Request, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || !s.now().Before(e.expires) {
+		return "", gologin.ErrStateNotFound
+	}
+	return e.value, nil
+}
+
+// Delete removes the value stored under the key. If no unexpired value was
+// stored, gologin.ErrStateNotFound is returned.
+func (s *MemoryStore) Delete(w http.ResponseWriter, req *http.Request, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return gologin.ErrStateNotFound
+	}
+	delete(s.entries, key)
+	if !s.now().Before(e.expires) {
+		return gologin.ErrStateNotFound
+	}
+	return nil
+}
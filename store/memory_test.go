@@ -0,0 +1,54 @@
+package store
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dghubble/gologin/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(time.Minute)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	assert.Nil(t, store.Save(w, req, "key", "value"))
+	value, err := store.Load(req, "key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", value)
+
+	// values are not shared between keys
+	_, err = store.Load(req, "other-key")
+	assert.Equal(t, gologin.ErrStateNotFound, err)
+
+	// deleted values cannot be loaded or deleted again
+	assert.Nil(t, store.Delete(w, req, "key"))
+	_, err = store.Load(req, "key")
+	assert.Equal(t, gologin.ErrStateNotFound, err)
+	assert.Equal(t, gologin.ErrStateNotFound, store.Delete(w, req, "key"))
+	// store never writes to the response
+	assert.Empty(t, w.Result().Cookies())
+}
+
+func TestMemoryStore_Expiry(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore(time.Minute)
+	store.now = func() time.Time { return now }
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	assert.Nil(t, store.Save(w, req, "key", "value"))
+	now = now.Add(2 * time.Minute)
+	_, err := store.Load(req, "key")
+	assert.Equal(t, gologin.ErrStateNotFound, err)
+	assert.Equal(t, gologin.ErrStateNotFound, store.Delete(w, req, "key"))
+	assert.Nil(t, store.Save(w, req, "key", "value"))
+	now = now.Add(2 * time.Minute)
+
+	// expired entries are swept on save
+	assert.Nil(t, store.Save(w, req, "other-key", "value"))
+	assert.Len(t, store.entries, 1)
+}
@@ -35,6 +35,19 @@ func CallbackHandler(config *oauth1.Config, cookieConfig gologin.CookieConfig, s
 	return oauth1Login.CookieTempHandler(cookieConfig, success, failure)
 }
 
+// NewProvider returns a gologin.Provider for mounting the Tumblr
+// LoginHandler and CallbackHandler on a gologin.Registry.
+func NewProvider(config *oauth1.Config, cookieConfig gologin.CookieConfig) gologin.Provider {
+	return gologin.ProviderFuncs{
+		Login: func(failure http.Handler) http.Handler {
+			return LoginHandler(config, cookieConfig, failure)
+		},
+		Callback: func(success, failure http.Handler) http.Handler {
+			return CallbackHandler(config, cookieConfig, success, failure)
+		},
+	}
+}
+
 // tumblrHandler is a http.Handler that gets the OAuth1 access token from
 // the ctx and obtains the Tumblr User. If successful, the User is added to
 // the ctx and the success handler is called. Otherwise, the failure handler
@@ -61,6 +74,7 @@ func tumblrHandler(config *oauth1.Config, success, failure http.Handler) http.Ha
 			return
 		}
 		ctx = WithUser(ctx, user)
+		ctx = gologin.WithIdentity(ctx, newIdentity(user))
 		success.ServeHTTP(w, req.WithContext(ctx))
 	}
 	return http.HandlerFunc(fn)
@@ -77,3 +91,17 @@ func validateResponse(user *User, resp *http.Response, err error) error {
 	}
 	return nil
 }
+
+// newIdentity returns the gologin Identity of the Tumblr User.
+//
+// Tumblr does not provide stable user identifiers, so the Subject is the
+// user name.
+func newIdentity(user *User) *gologin.Identity {
+	return &gologin.Identity{
+		Provider: "tumblr",
+		Subject:  user.Name,
+		Name:     user.Name,
+		Username: user.Name,
+		Raw:      user,
+	}
+}
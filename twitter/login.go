@@ -34,6 +34,19 @@ func CallbackHandler(config *oauth1.Config, success, failure http.Handler) http.
 	return oauth1Login.EmptyTempHandler(success)
 }
 
+// NewProvider returns a gologin.Provider for mounting the Twitter
+// LoginHandler and CallbackHandler on a gologin.Registry.
+func NewProvider(config *oauth1.Config) gologin.Provider {
+	return gologin.ProviderFuncs{
+		Login: func(failure http.Handler) http.Handler {
+			return LoginHandler(config, failure)
+		},
+		Callback: func(success, failure http.Handler) http.Handler {
+			return CallbackHandler(config, success, failure)
+		},
+	}
+}
+
 // twitterHandler is a http.Handler that gets the OAuth1 access token from
 // the ctx and calls Twitter verify_credentials to get the corresponding User.
 // If successful, the User is added to the ctx and the success handler is
@@ -65,6 +78,7 @@ func twitterHandler(config *oauth1.Config, success, failure http.Handler) http.H
 			return
 		}
 		ctx = WithUser(ctx, user)
+		ctx = gologin.WithIdentity(ctx, newIdentity(user))
 		success.ServeHTTP(w, req.WithContext(ctx))
 	}
 	return http.HandlerFunc(fn)
@@ -81,3 +95,16 @@ func validateResponse(user *twitter.User, resp *http.Response, err error) error
 	}
 	return nil
 }
+
+// newIdentity returns the gologin Identity of the Twitter User.
+func newIdentity(user *twitter.User) *gologin.Identity {
+	return &gologin.Identity{
+		Provider:  "twitter",
+		Subject:   user.IDStr,
+		Email:     user.Email,
+		Name:      user.Name,
+		Username:  user.ScreenName,
+		AvatarURL: user.ProfileImageURLHttps,
+		Raw:       user,
+	}
+}